@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxAuditPageSize bounds the number of balances one AuditSupply call returns
+const maxAuditPageSize = 500
+
+// balanceKeyPrefix is the key prefix of public balances; balanceKeyEnd sorts right after every key with it
+const (
+	balanceKeyPrefix = "BALANCE_"
+	balanceKeyEnd    = "BALANCE`"
+)
+
+// AuditedBalance is one balance record read by AuditSupply
+type AuditedBalance struct {
+	Address string `json:"address"`                                // address in the key
+	Amount  string `json:"amount"`                                 // amount as stored, in raw units
+	Problem string `json:"problem,omitempty" metadata:",optional"` // why the record was left out of the sums
+}
+
+// SupplyAudit is one page of AuditSupply. The sums run over every page read so far;
+// once done is true they cover every public balance on the ledger.
+type SupplyAudit struct {
+	Balances     []*AuditedBalance `json:"balances"`
+	PageSum      string            `json:"pageSum"`      // sum of the valid balances on this page
+	RunningSum   string            `json:"runningSum"`   // sum of the valid balances on this and earlier pages
+	RunningCount int               `json:"runningCount"` // balance records on this and earlier pages
+	Bookmark     string            `json:"bookmark"`     // pass to the next AuditSupply call; empty when done
+	Done         bool              `json:"done"`
+
+	// Only set on the last page
+	TotalSupply  string `json:"totalSupply,omitempty" metadata:",optional"`
+	Difference   string `json:"difference,omitempty" metadata:",optional"` // totalSupply minus runningSum
+	Consistent   bool   `json:"consistent"`
+	Confidential bool   `json:"confidential"` // private balances are not audited, so the difference is what they hold
+}
+
+// AuditSupply walks the public balances a page at a time and sums them, to check that they add
+// up to TotalSupply. Start with an empty bookmark and pass the returned one until done is true.
+// The bookmark carries the running sums, so the result of the last page is the full audit.
+// Evaluate it on one peer; balances that change between pages make the sums meaningless.
+func (s *BobCoinContract) AuditSupply(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*SupplyAudit, error) {
+	if pageSize <= 0 || pageSize > maxAuditPageSize {
+		return nil, fmt.Errorf("pageSize must be between 1 and %d", maxAuditPageSize)
+	}
+
+	runningCount, runningSum, startKey, err := parseAuditBookmark(bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	// The range is cut by hand so the bookmark can carry the running sums
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, balanceKeyEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balances: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	audit := &SupplyAudit{Balances: []*AuditedBalance{}}
+	pageSum := big.NewInt(0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next balance: %v", err)
+		}
+
+		if len(audit.Balances) == pageSize {
+			audit.Bookmark = formatAuditBookmark(runningCount+len(audit.Balances), new(big.Int).Add(runningSum, pageSum), queryResponse.Key)
+			break
+		}
+
+		balance, amount := auditBalance(queryResponse.Key, queryResponse.Value)
+		if amount != nil {
+			pageSum.Add(pageSum, amount)
+		}
+		audit.Balances = append(audit.Balances, balance)
+	}
+
+	runningSum.Add(runningSum, pageSum)
+	audit.PageSum = pageSum.String()
+	audit.RunningSum = runningSum.String()
+	audit.RunningCount = runningCount + len(audit.Balances)
+	audit.Done = audit.Bookmark == ""
+	if !audit.Done {
+		return audit, nil
+	}
+
+	supply, err := getTotalSupply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	confidential, err := isConfidential(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.TotalSupply = supply.String()
+	audit.Difference = new(big.Int).Sub(supply, runningSum).String()
+	audit.Consistent = supply.Cmp(runningSum) == 0
+	audit.Confidential = confidential
+	return audit, nil
+}
+
+// auditBalance reads one balance record and returns its amount, or nil with a problem
+// if the record cannot be counted
+func auditBalance(key string, value []byte) (*AuditedBalance, *big.Int) {
+	audited := &AuditedBalance{Address: strings.TrimPrefix(key, balanceKeyPrefix)}
+
+	var balance Balance
+	err := json.Unmarshal(value, &balance)
+	if err != nil {
+		audited.Problem = fmt.Sprintf("record is not valid JSON: %v", err)
+		return audited, nil
+	}
+	audited.Amount = balance.Amount
+
+	if balance.Address != audited.Address {
+		audited.Problem = fmt.Sprintf("record is for address %s", balance.Address)
+		return audited, nil
+	}
+
+	amount, err := parseStoredAmount(balance.Amount)
+	if err != nil {
+		audited.Problem = err.Error()
+		return audited, nil
+	}
+
+	return audited, amount
+}
+
+// AuditSupply bookmarks are "<count>:<sum>:<next key>"
+func formatAuditBookmark(count int, sum *big.Int, nextKey string) string {
+	return fmt.Sprintf("%d:%s:%s", count, sum.String(), nextKey)
+}
+
+// parseAuditBookmark returns the running count and sum and the key to resume from
+func parseAuditBookmark(bookmark string) (int, *big.Int, string, error) {
+	if bookmark == "" {
+		return 0, big.NewInt(0), balanceKeyPrefix, nil
+	}
+
+	parts := strings.SplitN(bookmark, ":", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], balanceKeyPrefix) {
+		return 0, nil, "", fmt.Errorf("invalid audit bookmark %s", bookmark)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 0 {
+		return 0, nil, "", fmt.Errorf("invalid audit bookmark %s", bookmark)
+	}
+	sum, ok := new(big.Int).SetString(parts[1], 10)
+	if !ok || sum.Sign() < 0 {
+		return 0, nil, "", fmt.Errorf("invalid audit bookmark %s", bookmark)
+	}
+
+	return count, sum, parts[2], nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"chaincode-common/events"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchTransfers bounds the number of entries in one BatchTransfer
+const maxBatchTransfers = 100
+
+// BatchTransferEntry is one payment of a BatchTransfer, in whole tokens
+type BatchTransferEntry struct {
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+// BatchTransfer pays several recipients from one address in a single transaction, e.g. a team
+// of freelancers. transfersJSON is a list of {"to","amount"} objects with amounts in whole
+// tokens. Either every payment is made or none is. Payments to the same recipient are added up.
+func (s *BobCoinContract) BatchTransfer(ctx contractapi.TransactionContextInterface, from string, transfersJSON string) error {
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	if token.Confidential {
+		return fmt.Errorf("BobCoin is in confidential mode; use ConfidentialTransfer")
+	}
+
+	var entries []*BatchTransferEntry
+	err = json.Unmarshal([]byte(transfersJSON), &entries)
+	if err != nil {
+		return fmt.Errorf("transfers must be a JSON list of {\"to\",\"amount\"} objects: %v", err)
+	}
+	if len(entries) == 0 || len(entries) > maxBatchTransfers {
+		return fmt.Errorf("a batch must have between 1 and %d transfers", maxBatchTransfers)
+	}
+
+	// A transaction does not see its own writes, so each recipient is written once with its total
+	var recipients []string
+	amounts := map[string]*big.Int{}
+	total := big.NewInt(0)
+	for i, entry := range entries {
+		if entry == nil || entry.To == "" {
+			return fmt.Errorf("transfer %d has no recipient", i)
+		}
+		if entry.To == from {
+			return fmt.Errorf("transfer %d pays the sender %s", i, from)
+		}
+
+		transferAmount, err := token.parseAmount(entry.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to parse amount of transfer %d: %v", i, err)
+		}
+		if transferAmount.Sign() <= 0 {
+			return fmt.Errorf("amount of transfer %d must be positive", i)
+		}
+
+		if _, exists := amounts[entry.To]; !exists {
+			recipients = append(recipients, entry.To)
+			amounts[entry.To] = big.NewInt(0)
+		}
+		amounts[entry.To].Add(amounts[entry.To], transferAmount)
+		total.Add(total, transferAmount)
+	}
+
+	// Read the sender's balance once and debit the whole batch
+	balance, err := getPublicBalance(ctx, from)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(total) < 0 {
+		return fmt.Errorf("insufficient balance for a batch of %s", token.formatAmount(total))
+	}
+	err = s.setBalance(ctx, from, new(big.Int).Sub(balance, total).String())
+	if err != nil {
+		return err
+	}
+
+	// Fabric keeps one event per transaction, so the payments to each recipient are listed in it
+	event := &events.BatchTransfer{From: from, Total: token.formatAmount(total), Transfers: []*events.BatchTransferEntry{}}
+	for _, to := range recipients {
+		recipientBal, err := getPublicBalance(ctx, to)
+		if err != nil {
+			return err
+		}
+		err = s.setBalance(ctx, to, new(big.Int).Add(recipientBal, amounts[to]).String())
+		if err != nil {
+			return err
+		}
+
+		event.Transfers = append(event.Transfers, &events.BatchTransferEntry{To: to, Amount: token.formatAmount(amounts[to])})
+	}
+
+	return events.Emit(ctx, event)
+}
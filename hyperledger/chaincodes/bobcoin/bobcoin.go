@@ -10,6 +10,10 @@ import (
 	"math/big"
 	"strings"
 
+	"chaincode-common/access"
+	"chaincode-common/amount"
+	"chaincode-common/events"
+	"chaincode-common/schema"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -24,46 +28,129 @@ type Token struct {
 	Symbol     string `json:"symbol"`
 	Decimals   int    `json:"decimals"`
 	TotalSupply string `json:"totalSupply"`
+	MaxSupply  string `json:"maxSupply,omitempty" metadata:",optional"` // raw units; empty means unlimited
+	Confidential bool `json:"confidential,omitempty" metadata:",optional"` // balances are kept in implicit org collections, see confidential.go
 }
 
 // Balance represents a user's token balance
 type Balance struct {
 	Address string `json:"address"`
 	Amount  string `json:"amount"`
+	Salt    string `json:"salt,omitempty" metadata:",optional"` // only on confidential balances
 }
 
-// InitLedger initializes the token contract with default values
-func (s *BobCoinContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+// schemaRegistry tracks the version of the token record layout. Version 1 is the
+// layout written before versioning; version 2 keeps the supply in delta records;
+// version 3 counted the capped supply and mint allowance usage in counter keys; version 4
+// goes back to per-mint records and caps the supply with per-minter quotas.
+var schemaRegistry = newSchemaRegistry()
+
+// newSchemaRegistry returns the token's schema registry; a ledger with token
+// metadata but no version was initialized before versioning
+func newSchemaRegistry() *schema.Registry {
+	registry := schema.NewRegistry("SCHEMA_VERSION", 4,
+		schema.Step{
+			Version:     2,
+			Description: "track the total supply as supply delta records",
+			// The supply in TOKEN_METADATA becomes the compacted supply; no records change
+			Apply: func(ctx contractapi.TransactionContextInterface) error { return nil },
+		},
+		schema.Step{
+			Version:     3,
+			Description: "count the capped supply and mint allowance usage in counter keys",
+			// Version 4 undoes this step and keeps version 2's mint records, so they stay as they are
+			Apply: func(ctx contractapi.TransactionContextInterface) error { return nil },
+		},
+		schema.Step{
+			Version:     4,
+			Description: "turn mint allowance usage back into mint records and drop the capped supply counter",
+			Apply:       migrateMintUsage,
+		},
+	)
+	registry.HasLegacyState = func(ctx contractapi.TransactionContextInterface) (bool, error) {
+		tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+		if err != nil {
+			return false, fmt.Errorf("failed to read token metadata: %v", err)
+		}
+		return tokenJSON != nil, nil
+	}
+	return registry
+}
+
+// InitLedger initializes the token contract with its name, symbol and number of decimals.
+// maxSupply is in whole tokens; empty or "0" means unlimited. Only an admin may call it.
+// It only writes the token metadata once; calling it again leaves the token untouched
+func (s *BobCoinContract) InitLedger(ctx contractapi.TransactionContextInterface, name string, symbol string, decimals int, maxSupply string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
 	token := Token{
-		Name:       "BobCoin",
-		Symbol:     "BOB",
-		Decimals:   18,
+		Name:       name,
+		Symbol:     symbol,
+		Decimals:   decimals,
 		TotalSupply: "0",
 	}
-
-	tokenJSON, err := json.Marshal(token)
+	err = validateTokenMetadata(&token)
 	if err != nil {
 		return err
 	}
+	if maxSupply != "" && maxSupply != "0" {
+		maxSupplyAmount, err := token.parseAmount(maxSupply)
+		if err != nil {
+			return fmt.Errorf("failed to parse max supply: %v", err)
+		}
+		token.MaxSupply = maxSupplyAmount.String()
+	}
+
+	_, err = schemaRegistry.Init(ctx, func() error {
+		tokenJSON, err := json.Marshal(token)
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PutState("TOKEN_METADATA", tokenJSON)
+		if err != nil {
+			return fmt.Errorf("failed to put token metadata: %v", err)
+		}
 
-	err = ctx.GetStub().PutState("TOKEN_METADATA", tokenJSON)
+		return nil
+	})
+	return err
+}
+
+// UpgradeSchema applies the next schema upgrade step after a chaincode upgrade; call it until done is true
+func (s *BobCoinContract) UpgradeSchema(ctx contractapi.TransactionContextInterface) (*schema.UpgradeResult, error) {
+	err := access.RequireAdmin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to put token metadata: %v", err)
+		return nil, err
 	}
 
-	return nil
+	return schemaRegistry.Upgrade(ctx)
+}
+
+// GetSchemaVersion returns the schema version of the ledger, or 0 before InitLedger
+func (s *BobCoinContract) GetSchemaVersion(ctx contractapi.TransactionContextInterface) (int, error) {
+	return schemaRegistry.Version(ctx)
 }
 
 // Mint creates new tokens and adds them to the specified address
 func (s *BobCoinContract) Mint(ctx contractapi.TransactionContextInterface, to string, amount string) error {
-	// Check if caller has minter role (in production, implement proper access control)
-	_, err := ctx.GetClientIdentity().GetMSPID()
+	// Only minters with an allowance may mint
+	minterId, err := getMinterID(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get client MSP ID: %v", err)
+		return err
 	}
 
-	// Parse amount using big.Int (handles any size, NO overflow!)
-	mintAmount, err := parseAmount(amount)
+	// Get token metadata
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Parse the display amount into raw units; malformed or over-precise amounts are rejected
+	mintAmount, err := token.parseAmount(amount)
 	if err != nil {
 		return fmt.Errorf("failed to parse mint amount: %v", err)
 	}
@@ -73,58 +160,43 @@ func (s *BobCoinContract) Mint(ctx contractapi.TransactionContextInterface, to s
 		return fmt.Errorf("mint amount must be positive")
 	}
 
-	// Get current total supply
-	totalSupply, err := s.TotalSupply(ctx)
+	// Enforce the caller's daily mint allowance and its share of the max supply
+	err = useMintAllowance(ctx, token, minterId, mintAmount)
 	if err != nil {
 		return err
 	}
-
-	// Get token metadata
-	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+	err = useSupplyQuota(ctx, token, minterId, mintAmount)
 	if err != nil {
-		return fmt.Errorf("failed to read token metadata: %v", err)
-	}
-	if tokenJSON == nil {
-		return fmt.Errorf("token metadata does not exist. Initialize first")
+		return err
 	}
 
-	// Calculate new total supply using big.Int
-	currentSupply, err := parseAmount(totalSupply)
+	// Record the supply change under its own key; rewriting TOKEN_METADATA here made
+	// concurrent mints in the same block fail with MVCC read conflicts
+	err = putSupplyDelta(ctx, mintAmount)
 	if err != nil {
-		return fmt.Errorf("failed to parse current supply: %v", err)
+		return err
 	}
 
-	// Add minted amount (big.Int math - always correct!)
-	newSupply := new(big.Int)
-	newSupply.Add(currentSupply, mintAmount)
+	// In confidential mode the recipient is paid with a credit in its organization's collection
+	if token.Confidential {
+		account, err := s.GetAccount(ctx, to)
+		if err != nil {
+			return err
+		}
+		err = putCredit(ctx, account, mintAmount)
+		if err != nil {
+			return err
+		}
 
-	// Update total supply
-	token := Token{}
-	err = json.Unmarshal(tokenJSON, &token)
-	if err != nil {
-		return err
-	}
-	token.TotalSupply = newSupply.String()
-	tokenJSON, err = json.Marshal(token)
-	if err != nil {
-		return err
-	}
-	err = ctx.GetStub().PutState("TOKEN_METADATA", tokenJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update total supply: %v", err)
+		return events.Emit(ctx, &events.Mint{To: to, Amount: token.formatAmount(mintAmount)})
 	}
 
 	// Add tokens to recipient's balance
-	currentBalance, err := s.BalanceOf(ctx, to)
+	balance, err := getPublicBalance(ctx, to)
 	if err != nil {
 		return err
 	}
 
-	balance, err := parseAmount(currentBalance)
-	if err != nil {
-		return fmt.Errorf("failed to parse balance: %v", err)
-	}
-
 	// Add minted amount to balance (big.Int math - always correct!)
 	newBalance := new(big.Int)
 	newBalance.Add(balance, mintAmount)
@@ -134,26 +206,18 @@ func (s *BobCoinContract) Mint(ctx contractapi.TransactionContextInterface, to s
 	}
 
 	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"Mint","to":"%s","amount":"%s","totalSupply":"%s"}`, to, amount, token.TotalSupply)
-	ctx.GetStub().SetEvent("Mint", []byte(eventPayload))
-
-	return nil
+	return events.Emit(ctx, &events.Mint{To: to, Amount: token.formatAmount(mintAmount)})
 }
 
 // Burn destroys tokens from the specified address
+// In confidential mode it burns from the private balance, so the owner's organization must endorse it
 func (s *BobCoinContract) Burn(ctx contractapi.TransactionContextInterface, from string, amount string) error {
-	// Get current balance
-	currentBalance, err := s.BalanceOf(ctx, from)
+	token, err := getTokenMetadata(ctx)
 	if err != nil {
 		return err
 	}
 
-	balance, err := parseAmount(currentBalance)
-	if err != nil {
-		return fmt.Errorf("failed to parse balance: %v", err)
-	}
-
-	burnAmount, err := parseAmount(amount)
+	burnAmount, err := token.parseAmount(amount)
 	if err != nil {
 		return fmt.Errorf("failed to parse burn amount: %v", err)
 	}
@@ -163,89 +227,102 @@ func (s *BobCoinContract) Burn(ctx contractapi.TransactionContextInterface, from
 		return fmt.Errorf("burn amount must be positive")
 	}
 
-	// Check sufficient balance using big.Int comparison
-	if balance.Cmp(burnAmount) < 0 {
-		return fmt.Errorf("insufficient balance to burn")
-	}
-
-	// Update balance using big.Int subtraction
-	newBalance := new(big.Int)
-	newBalance.Sub(balance, burnAmount)
-	err = s.setBalance(ctx, from, newBalance.String())
-	if err != nil {
-		return err
-	}
+	if token.Confidential {
+		account, err := requireAccountOwner(ctx, from)
+		if err != nil {
+			return err
+		}
 
-	// Update total supply
-	totalSupply, err := s.TotalSupply(ctx)
-	if err != nil {
-		return err
-	}
+		balance, err := consolidateBalance(ctx, account)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(burnAmount) < 0 {
+			return fmt.Errorf("insufficient balance to burn")
+		}
 
-	currentSupply, err := parseAmount(totalSupply)
-	if err != nil {
-		return fmt.Errorf("failed to parse total supply: %v", err)
-	}
+		err = putConfidentialBalance(ctx, account, new(big.Int).Sub(balance, burnAmount))
+		if err != nil {
+			return err
+		}
+	} else {
+		// Get current balance
+		balance, err := getPublicBalance(ctx, from)
+		if err != nil {
+			return err
+		}
 
-	// Subtract burned amount from total supply
-	newSupply := new(big.Int)
-	newSupply.Sub(currentSupply, burnAmount)
+		// Check sufficient balance using big.Int comparison
+		if balance.Cmp(burnAmount) < 0 {
+			return fmt.Errorf("insufficient balance to burn")
+		}
 
-	// Update token metadata
-	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
-	if err != nil {
-		return fmt.Errorf("failed to read token metadata: %v", err)
+		// Update balance using big.Int subtraction
+		newBalance := new(big.Int)
+		newBalance.Sub(balance, burnAmount)
+		err = s.setBalance(ctx, from, newBalance.String())
+		if err != nil {
+			return err
+		}
 	}
 
-	token := Token{}
-	err = json.Unmarshal(tokenJSON, &token)
+	// Record the supply change
+	err = putSupplyDelta(ctx, new(big.Int).Neg(burnAmount))
 	if err != nil {
 		return err
 	}
-	token.TotalSupply = newSupply.String()
-	tokenJSON, err = json.Marshal(token)
+
+	// Emit event
+	return events.Emit(ctx, &events.Burn{From: from, Amount: token.formatAmount(burnAmount)})
+}
+
+// Transfer moves tokens from one address to another
+// In confidential mode amounts must stay off the ledger, so ConfidentialTransfer is used instead
+func (s *BobCoinContract) Transfer(ctx contractapi.TransactionContextInterface, from string, to string, amount string) error {
+	token, transferAmount, err := s.transfer(ctx, from, to, amount)
 	if err != nil {
 		return err
 	}
-	err = ctx.GetStub().PutState("TOKEN_METADATA", tokenJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update total supply: %v", err)
-	}
 
 	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"Burn","from":"%s","amount":"%s","totalSupply":"%s"}`, from, amount, token.TotalSupply)
-	ctx.GetStub().SetEvent("Burn", []byte(eventPayload))
-
-	return nil
+	return events.Emit(ctx, &events.Transfer{From: from, To: to, Amount: token.formatAmount(transferAmount)})
 }
 
-// Transfer moves tokens from one address to another
-func (s *BobCoinContract) Transfer(ctx contractapi.TransactionContextInterface, from string, to string, amount string) error {
-	// Parse transfer amount using big.Int
-	transferAmount, err := parseAmount(amount)
+// transfer moves a display amount between public balances and returns the token and the raw amount
+func (s *BobCoinContract) transfer(ctx contractapi.TransactionContextInterface, from string, to string, amount string) (*Token, *big.Int, error) {
+	token, err := getTokenMetadata(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to parse transfer amount: %v", err)
+		return nil, nil, err
+	}
+	if token.Confidential {
+		return nil, nil, fmt.Errorf("BobCoin is in confidential mode; use ConfidentialTransfer")
 	}
 
-	// Validate amount is positive
-	if transferAmount.Sign() <= 0 {
-		return fmt.Errorf("transfer amount must be positive")
+	// Both balances are read before either is written, so a self-transfer would credit the sender
+	if from == to {
+		return nil, nil, fmt.Errorf("cannot transfer from %s to itself", from)
 	}
 
-	// Get sender's balance
-	senderBalance, err := s.BalanceOf(ctx, from)
+	// Parse the display amount into raw units
+	transferAmount, err := token.parseAmount(amount)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to parse transfer amount: %v", err)
 	}
 
-	balance, err := parseAmount(senderBalance)
+	// Validate amount is positive
+	if transferAmount.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("transfer amount must be positive")
+	}
+
+	// Get sender's balance
+	balance, err := getPublicBalance(ctx, from)
 	if err != nil {
-		return fmt.Errorf("failed to parse sender balance: %v", err)
+		return nil, nil, err
 	}
 
 	// Check sufficient balance using big.Int comparison
 	if balance.Cmp(transferAmount) < 0 {
-		return fmt.Errorf("insufficient balance")
+		return nil, nil, fmt.Errorf("insufficient balance")
 	}
 
 	// Update sender balance using big.Int subtraction
@@ -253,18 +330,13 @@ func (s *BobCoinContract) Transfer(ctx contractapi.TransactionContextInterface,
 	newSenderBalance.Sub(balance, transferAmount)
 	err = s.setBalance(ctx, from, newSenderBalance.String())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Update recipient balance
-	recipientBalance, err := s.BalanceOf(ctx, to)
-	if err != nil {
-		return err
-	}
-
-	recipientBal, err := parseAmount(recipientBalance)
+	recipientBal, err := getPublicBalance(ctx, to)
 	if err != nil {
-		return fmt.Errorf("failed to parse recipient balance: %v", err)
+		return nil, nil, err
 	}
 
 	// Add to recipient balance using big.Int addition
@@ -272,85 +344,90 @@ func (s *BobCoinContract) Transfer(ctx contractapi.TransactionContextInterface,
 	newRecipientBalance.Add(recipientBal, transferAmount)
 	err = s.setBalance(ctx, to, newRecipientBalance.String())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"Transfer","from":"%s","to":"%s","amount":"%s"}`, from, to, amount)
-	ctx.GetStub().SetEvent("Transfer", []byte(eventPayload))
-
-	return nil
+	return token, transferAmount, nil
 }
 
 // BalanceOf returns the token balance of the specified address
 // Always returns raw big.Int string (no decimal formatting)
+// In confidential mode the balance of a registered account also includes its private records,
+// which only peers of the owning organization can read
 func (s *BobCoinContract) BalanceOf(ctx contractapi.TransactionContextInterface, address string) (string, error) {
-	balanceKey := fmt.Sprintf("BALANCE_%s", address)
-	balanceJSON, err := ctx.GetStub().GetState(balanceKey)
+	balance, err := getBalance(ctx, address)
 	if err != nil {
-		return "", fmt.Errorf("failed to read balance: %v", err)
+		return "", err
 	}
 
-	if balanceJSON == nil {
-		return "0", nil
-	}
+	return balance.String(), nil
+}
 
-	var balance Balance
-	err = json.Unmarshal(balanceJSON, &balance)
+// BalanceOfFormatted returns the balance of the specified address in whole tokens, e.g. "1.5"
+func (s *BobCoinContract) BalanceOfFormatted(ctx contractapi.TransactionContextInterface, address string) (string, error) {
+	token, err := getTokenMetadata(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal balance: %v", err)
+		return "", err
 	}
 
-	// Parse the stored amount (may be decimal string from old version) and return raw big.Int string
-	amount, err := parseAmount(balance.Amount)
+	balance, err := getBalance(ctx, address)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse balance amount: %v", err)
+		return "", err
 	}
 
-	// Return raw big.Int string (no decimal formatting)
-	return amount.String(), nil
+	return token.formatAmount(balance), nil
 }
 
 // TotalSupply returns the total supply of tokens
+// It adds the supply deltas written since the last CompactSupply to the compacted supply
 func (s *BobCoinContract) TotalSupply(ctx contractapi.TransactionContextInterface) (string, error) {
-	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+	supply, err := getTotalSupply(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to read token metadata: %v", err)
+		return "", err
 	}
-	if tokenJSON == nil {
-		return "0", nil
+
+	return supply.String(), nil
+}
+
+// TotalSupplyFormatted returns the total supply in whole tokens, e.g. "1000000.25"
+func (s *BobCoinContract) TotalSupplyFormatted(ctx contractapi.TransactionContextInterface) (string, error) {
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	var token Token
-	err = json.Unmarshal(tokenJSON, &token)
+	supply, err := getTotalSupply(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal token: %v", err)
+		return "", err
 	}
 
-	return token.TotalSupply, nil
+	return token.formatAmount(supply), nil
 }
 
 // GetTokenInfo returns token metadata
 func (s *BobCoinContract) GetTokenInfo(ctx contractapi.TransactionContextInterface) (*Token, error) {
-	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+	token, err := getTokenMetadata(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read token metadata: %v", err)
-	}
-	if tokenJSON == nil {
-		return nil, fmt.Errorf("token metadata does not exist")
+		return nil, err
 	}
 
-	var token Token
-	err = json.Unmarshal(tokenJSON, &token)
+	supply, err := getTotalSupply(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+		return nil, err
 	}
+	token.TotalSupply = supply.String()
 
-	return &token, nil
+	return token, nil
 }
 
 // setBalance is a helper function to set balance for an address
+// It fails while the token is paused or the address is frozen
 func (s *BobCoinContract) setBalance(ctx contractapi.TransactionContextInterface, address string, amount string) error {
+	err := requireBalanceWritable(ctx, address)
+	if err != nil {
+		return err
+	}
+
 	balanceKey := fmt.Sprintf("BALANCE_%s", address)
 	balance := Balance{
 		Address: address,
@@ -365,77 +442,57 @@ func (s *BobCoinContract) setBalance(ctx contractapi.TransactionContextInterface
 	return ctx.GetStub().PutState(balanceKey, balanceJSON)
 }
 
-// Helper functions for amount parsing using big.Int
-func parseAmount(amountStr string) (*big.Int, error) {
-	// Handle empty or zero
-	if amountStr == "" || amountStr == "0" {
-		return big.NewInt(0), nil
-	}
-
-	// Remove any whitespace
-	amountStr = strings.TrimSpace(amountStr)
-
-	// Split by decimal point
-	parts := strings.Split(amountStr, ".")
-	if len(parts) > 2 {
-		return nil, fmt.Errorf("invalid amount format: %s", amountStr)
+// getBalance returns the balance of an address in raw units, including its private
+// records in confidential mode
+func getBalance(ctx contractapi.TransactionContextInterface, address string) (*big.Int, error) {
+	confidential, err := isConfidential(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	wholePart := parts[0]
-	decimalPart := "0"
-	if len(parts) == 2 {
-		decimalPart = parts[1]
-		// Pad or truncate to 18 decimals
-		if len(decimalPart) < 18 {
-			decimalPart = decimalPart + strings.Repeat("0", 18-len(decimalPart))
-		} else if len(decimalPart) > 18 {
-			decimalPart = decimalPart[:18]
+	if confidential {
+		account, err := getAccount(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if account != nil {
+			publicBalance, err := getPublicBalance(ctx, address)
+			if err != nil {
+				return nil, err
+			}
+			privateBalance, err := getConfidentialBalance(ctx, account)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Int).Add(publicBalance, privateBalance), nil
 		}
-	} else {
-		decimalPart = strings.Repeat("0", 18)
-	}
-
-	// Combine whole and decimal parts
-	combined := wholePart + decimalPart
-
-	// Parse as big.Int (handles any size, NO overflow!)
-	amount := new(big.Int)
-	amount, ok := amount.SetString(combined, 10)
-	if !ok {
-		return nil, fmt.Errorf("failed to parse amount: %s", amountStr)
 	}
 
-	return amount, nil
+	return getPublicBalance(ctx, address)
 }
 
-func formatAmount(amount *big.Int) string {
-	// Handle zero
-	if amount.Sign() == 0 {
-		return "0"
-	}
-
-	// Convert to string with padding for decimals
-	amountStr := amount.String()
+// Amounts passed to and emitted by transactions are display amounts in whole tokens;
+// the ledger stores and BalanceOf and TotalSupply return raw amounts. See chaincode-common/amount.
 
-	// Pad with zeros to ensure we have at least 18 digits for decimal part
-	if len(amountStr) <= 18 {
-		amountStr = strings.Repeat("0", 19-len(amountStr)) + amountStr
-	}
+// legacyDecimals is the number of decimals of amounts stored before big.Int amounts
+const legacyDecimals = 18
 
-	// Split into whole and decimal parts
-	wholePart := amountStr[:len(amountStr)-18]
-	decimalPart := amountStr[len(amountStr)-18:]
+// parseAmount parses an amount in whole tokens into raw units
+func (t *Token) parseAmount(s string) (*big.Int, error) {
+	return amount.ParseDisplay(s, t.Decimals)
+}
 
-	// Remove trailing zeros from decimal part
-	decimalPart = strings.TrimRight(decimalPart, "0")
+// formatAmount formats a raw amount in whole tokens
+func (t *Token) formatAmount(raw *big.Int) string {
+	return amount.FormatDisplay(raw, t.Decimals)
+}
 
-	// If no decimal part, return whole part only
-	if decimalPart == "" {
-		return wholePart
+// parseStoredAmount parses an amount as stored on the ledger, in raw units. Versions before
+// big.Int amounts stored display amounts, which are recognised by their decimal point.
+func parseStoredAmount(s string) (*big.Int, error) {
+	if strings.Contains(s, ".") {
+		return amount.ParseDisplay(s, legacyDecimals)
 	}
-
-	// Return formatted amount
-	return wholePart + "." + decimalPart
+	return amount.ParseRaw(s)
 }
 
 func main() {
@@ -0,0 +1,199 @@
+// Command audit-supply checks that the BobCoin balances on a running network add up to the
+// total supply. It pages through AuditSupply with the peer CLI, so it needs the same
+// environment as the deploy scripts (PATH, FABRIC_CFG_PATH and the CORE_PEER_* variables),
+// and reports every address whose record could not be counted or whose BalanceOf does not
+// match the stored amount.
+//
+//	go run ./cmd/audit-supply -channel mychannel -chaincode bobcoin
+//
+// It exits with status 1 if it finds a discrepancy.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"chaincode-common/amount"
+)
+
+// legacyDecimals is the number of decimals of the display amounts the chaincode stored
+// before big.Int amounts, as in the chaincode
+const legacyDecimals = 18
+
+// auditedBalance and supplyAudit mirror the chaincode's AuditSupply result
+type auditedBalance struct {
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
+	Problem string `json:"problem"`
+}
+
+type supplyAudit struct {
+	Balances     []*auditedBalance `json:"balances"`
+	RunningSum   string            `json:"runningSum"`
+	RunningCount int               `json:"runningCount"`
+	Bookmark     string            `json:"bookmark"`
+	Done         bool              `json:"done"`
+	TotalSupply  string            `json:"totalSupply"`
+	Difference   string            `json:"difference"`
+	Consistent   bool              `json:"consistent"`
+	Confidential bool              `json:"confidential"`
+}
+
+// discrepancy is something wrong with one address
+type discrepancy struct {
+	address string
+	reason  string
+}
+
+func main() {
+	channel := flag.String("channel", "mychannel", "channel the chaincode is deployed on")
+	chaincode := flag.String("chaincode", "bobcoin", "name of the BobCoin chaincode")
+	pageSize := flag.Int("page", 100, "balances to read per AuditSupply call")
+	checkBalances := flag.Bool("balances", true, "also compare BalanceOf with the stored amount of every address")
+	flag.Parse()
+
+	client := &peerClient{channel: *channel, chaincode: *chaincode}
+
+	var discrepancies []discrepancy
+	var audit *supplyAudit
+	bookmark := ""
+	for {
+		var err error
+		audit, err = client.auditPage(*pageSize, bookmark)
+		if err != nil {
+			fail(err)
+		}
+
+		for _, balance := range audit.Balances {
+			if balance.Problem != "" {
+				discrepancies = append(discrepancies, discrepancy{balance.Address, balance.Problem})
+				continue
+			}
+			// Registered accounts hold private balances in confidential mode, which BalanceOf adds
+			if *checkBalances && !audit.Confidential {
+				reason, err := client.checkBalance(balance)
+				if err != nil {
+					fail(err)
+				}
+				if reason != "" {
+					discrepancies = append(discrepancies, discrepancy{balance.Address, reason})
+				}
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "read %d balances\n", audit.RunningCount)
+		if audit.Done {
+			break
+		}
+		bookmark = audit.Bookmark
+	}
+
+	fmt.Printf("Balances:     %d\n", audit.RunningCount)
+	fmt.Printf("Sum:          %s\n", audit.RunningSum)
+	fmt.Printf("Total supply: %s\n", audit.TotalSupply)
+	fmt.Printf("Difference:   %s\n", audit.Difference)
+	// Private balances can only make up a shortfall; public balances above the supply are always wrong
+	difference, ok := new(big.Int).SetString(audit.Difference, 10)
+	if !ok {
+		fail(fmt.Errorf("AuditSupply returned an invalid difference %q", audit.Difference))
+	}
+	if audit.Confidential {
+		fmt.Println("Confidential mode is on: private balances are not audited and make up the difference")
+		if difference.Sign() < 0 {
+			fmt.Println("Public balances exceed the total supply")
+		}
+	}
+
+	if len(discrepancies) > 0 {
+		fmt.Printf("\n%d discrepancies:\n", len(discrepancies))
+		for _, d := range discrepancies {
+			fmt.Printf("  %s: %s\n", d.address, d.reason)
+		}
+	}
+
+	if len(discrepancies) > 0 || (!audit.Consistent && !audit.Confidential) || difference.Sign() < 0 {
+		os.Exit(1)
+	}
+	fmt.Println("\nBalances add up to the total supply")
+}
+
+// peerClient evaluates BobCoin queries with the peer CLI
+type peerClient struct {
+	channel   string
+	chaincode string
+}
+
+// auditPage returns one page of AuditSupply
+func (c *peerClient) auditPage(pageSize int, bookmark string) (*supplyAudit, error) {
+	output, err := c.query("AuditSupply", strconv.Itoa(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	var audit supplyAudit
+	err = json.Unmarshal(output, &audit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AuditSupply result: %v", err)
+	}
+
+	return &audit, nil
+}
+
+// checkBalance compares BalanceOf with the stored amount and returns why they differ, if they do
+func (c *peerClient) checkBalance(balance *auditedBalance) (string, error) {
+	output, err := c.query("BalanceOf", balance.Address)
+	if err != nil {
+		return "", err
+	}
+
+	reported, ok := new(big.Int).SetString(strings.Trim(strings.TrimSpace(string(output)), `"`), 10)
+	if !ok {
+		return fmt.Sprintf("BalanceOf returned %s", strings.TrimSpace(string(output))), nil
+	}
+	stored, err := parseStoredAmount(balance.Amount)
+	if err != nil {
+		return fmt.Sprintf("stored amount %q cannot be parsed: %v", balance.Amount, err), nil
+	}
+	if reported.Cmp(stored) != 0 {
+		return fmt.Sprintf("BalanceOf reports %s but %s is stored", reported, stored), nil
+	}
+
+	return "", nil
+}
+
+// query evaluates a chaincode function and returns its output
+func (c *peerClient) query(function string, args ...string) ([]byte, error) {
+	invocation, err := json.Marshal(map[string]interface{}{"function": function, "Args": args})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("peer", "chaincode", "query", "-C", c.channel, "-n", c.chaincode, "-c", string(invocation))
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %v", function, err)
+	}
+
+	return output, nil
+}
+
+// parseStoredAmount parses an amount as stored on the ledger, like the chaincode does: legacy
+// display amounts are recognised by their decimal point
+func parseStoredAmount(s string) (*big.Int, error) {
+	if strings.Contains(s, ".") {
+		return amount.ParseDisplay(s, legacyDecimals)
+	}
+	return amount.ParseRaw(s)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
@@ -0,0 +1,774 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"chaincode-common/access"
+	"chaincode-common/events"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// In confidential mode balances are kept in the implicit private collection of the
+// organization that owns the account, so only that organization's peers store them.
+// Other organizations cannot read a recipient's balance, so they pay it by writing a
+// credit record to the recipient's collection without reading it. The owner folds
+// credits into the balance whenever it spends. Each credit also gets a public marker
+// without the amount, so auditors know which records make up a balance, and Fabric
+// keeps the hash of every private record on the public ledger.
+//
+// The keys of an account, its public record, private balance and credits, carry a
+// state-based endorsement policy naming only the owning organization's peers. The
+// chaincode policy still covers every other key, but no other organization can endorse
+// a change to an account once its keys exist.
+
+// Transient map keys read by the confidential transactions
+const (
+	transientTransfer = "transfer" // ConfidentialTransfer input
+	transientSalt     = "salt"     // required, mixed into private records so their hashes cannot be guessed
+	transientRecords  = "records"  // ReconcileBalance input
+)
+
+// minSaltLength is the fewest bytes of salt a transaction writing private records must send
+const minSaltLength = 16
+
+// creditObjectType is the composite key object type of credit records and their public markers
+const creditObjectType = "credit"
+
+// Account records the organization that owns an address
+type Account struct {
+	Address string `json:"address"`
+	MSPID   string `json:"mspId"`
+}
+
+// Credit is an amount paid to a confidential account and not yet folded into its balance
+type Credit struct {
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
+	TxID    string `json:"txId"`
+	Salt    string `json:"salt,omitempty" metadata:",optional"`
+}
+
+// ConfidentialTransferInput is the transient "transfer" input of ConfidentialTransfer
+type ConfidentialTransferInput struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+// RecordHash is the hash Fabric keeps of a private record
+type RecordHash struct {
+	Key  string `json:"key"`
+	Hash string `json:"hash"` // hex SHA-256 of the record, empty if it does not exist
+}
+
+// BalanceHashes lists the hashes of the private records that make up a confidential balance
+type BalanceHashes struct {
+	Address    string        `json:"address"`
+	MSPID      string        `json:"mspId"`
+	Collection string        `json:"collection"`
+	Balance    RecordHash    `json:"balance"`
+	Credits    []*RecordHash `json:"credits"`
+}
+
+// DisclosedRecords is the transient "records" input of ReconcileBalance: the exact bytes of
+// the private records of an account, as handed over by its owner
+type DisclosedRecords struct {
+	Balance string   `json:"balance,omitempty"`
+	Credits []string `json:"credits,omitempty"`
+}
+
+// ReconciliationResult reports whether disclosed records match the hashes on the ledger
+type ReconciliationResult struct {
+	Address       string   `json:"address"`
+	Balance       string   `json:"balance"` // sum of the disclosed records, raw units
+	Consistent    bool     `json:"consistent"`
+	Discrepancies []string `json:"discrepancies"`
+}
+
+// EnableConfidentialMode moves BobCoin to confidential balances. It cannot be undone, since
+// turning it off would have to publish every balance. Public balances stay where they are
+// until their owners call ShieldBalance.
+func (s *BobCoinContract) EnableConfidentialMode(ctx contractapi.TransactionContextInterface) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	if token.Confidential {
+		return fmt.Errorf("confidential mode is already enabled")
+	}
+
+	token.Confidential = true
+	err = putTokenMetadata(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return events.Emit(ctx, &events.ConfidentialModeEnabled{})
+}
+
+// RegisterAccount assigns an address to the caller's organization, whose implicit collection
+// will hold its balance in confidential mode. The caller must hold the address, i.e. its user
+// ID must be the address, or be an admin of the organization registering it on the holder's behalf.
+func (s *BobCoinContract) RegisterAccount(ctx contractapi.TransactionContextInterface, address string) error {
+	if address == "" {
+		return fmt.Errorf("address is required")
+	}
+
+	err := requireAddressHolder(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getAccount(ctx, address)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("account %s is already registered to %s", address, existing.MSPID)
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	accountJSON, err := json.Marshal(Account{Address: address, MSPID: mspId})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(accountKey(address), accountJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put account: %v", err)
+	}
+
+	return setOwnerPolicy(ctx, "", accountKey(address), mspId)
+}
+
+// GetAccount returns the organization that owns an address
+func (s *BobCoinContract) GetAccount(ctx contractapi.TransactionContextInterface, address string) (*Account, error) {
+	account, err := getAccount(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %s is not registered", address)
+	}
+
+	return account, nil
+}
+
+// ConfidentialTransfer moves tokens between confidential accounts. from, to and amount are read
+// from the transient "transfer" field so they stay off the ledger. The caller and the endorsing
+// peers must belong to the sender's organization, which is the only one that can read its balance.
+func (s *BobCoinContract) ConfidentialTransfer(ctx contractapi.TransactionContextInterface) error {
+	token, err := requireConfidentialMode(ctx)
+	if err != nil {
+		return err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to read transient data: %v", err)
+	}
+	inputJSON, ok := transientMap[transientTransfer]
+	if !ok {
+		return fmt.Errorf("transient field %s is required", transientTransfer)
+	}
+
+	var input ConfidentialTransferInput
+	err = json.Unmarshal(inputJSON, &input)
+	if err != nil {
+		return fmt.Errorf("transient field %s is not valid JSON: %v", transientTransfer, err)
+	}
+	if input.From == "" || input.To == "" {
+		return fmt.Errorf("from and to are required")
+	}
+
+	transferAmount, err := token.parseAmount(input.Amount)
+	if err != nil {
+		return fmt.Errorf("failed to parse transfer amount: %v", err)
+	}
+	if transferAmount.Sign() <= 0 {
+		return fmt.Errorf("transfer amount must be positive")
+	}
+
+	sender, err := requireAccountOwner(ctx, input.From)
+	if err != nil {
+		return err
+	}
+	recipient, err := s.GetAccount(ctx, input.To)
+	if err != nil {
+		return err
+	}
+
+	balance, err := consolidateBalance(ctx, sender)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(transferAmount) < 0 {
+		return fmt.Errorf("insufficient balance")
+	}
+
+	err = putConfidentialBalance(ctx, sender, new(big.Int).Sub(balance, transferAmount))
+	if err != nil {
+		return err
+	}
+
+	err = putCredit(ctx, recipient, transferAmount)
+	if err != nil {
+		return err
+	}
+
+	// The event names the parties but not the amount
+	return events.Emit(ctx, &events.ConfidentialTransfer{From: input.From, To: input.To})
+}
+
+// ShieldBalance moves the public balance of an address into its organization's collection.
+// The amount is public until then anyway; after it only hashes are.
+func (s *BobCoinContract) ShieldBalance(ctx contractapi.TransactionContextInterface, address string) error {
+	_, err := requireConfidentialMode(ctx)
+	if err != nil {
+		return err
+	}
+
+	account, err := requireAccountOwner(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	publicBalance, err := getPublicBalance(ctx, address)
+	if err != nil {
+		return err
+	}
+	if publicBalance.Sign() == 0 {
+		return fmt.Errorf("address %s has no public balance", address)
+	}
+
+	err = ctx.GetStub().DelState(fmt.Sprintf("BALANCE_%s", address))
+	if err != nil {
+		return fmt.Errorf("failed to delete public balance: %v", err)
+	}
+
+	return putCredit(ctx, account, publicBalance)
+}
+
+// GetBalanceHashes returns the ledger hashes of the private records that make up the balance of
+// an address. Any organization can call it; auditors compare them with records the owner discloses.
+func (s *BobCoinContract) GetBalanceHashes(ctx contractapi.TransactionContextInterface, address string) (*BalanceHashes, error) {
+	account, err := s.GetAccount(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	collection := implicitCollection(account.MSPID)
+
+	balanceHash, err := privateRecordHash(ctx, collection, fmt.Sprintf("BALANCE_%s", address))
+	if err != nil {
+		return nil, err
+	}
+
+	creditKeys, err := getCreditKeys(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	credits := []*RecordHash{}
+	for _, creditKey := range creditKeys {
+		creditHash, err := privateRecordHash(ctx, collection, creditKey)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, creditHash)
+	}
+
+	return &BalanceHashes{
+		Address:    address,
+		MSPID:      account.MSPID,
+		Collection: collection,
+		Balance:    *balanceHash,
+		Credits:    credits,
+	}, nil
+}
+
+// ReconcileBalance checks records disclosed by an account's owner, passed in the transient
+// "records" field, against the hashes on the ledger. A regulator learns the balance without
+// being a member of the owner's collection, and knows no record was left out.
+func (s *BobCoinContract) ReconcileBalance(ctx contractapi.TransactionContextInterface, address string) (*ReconciliationResult, error) {
+	hashes, err := s.GetBalanceHashes(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	recordsJSON, ok := transientMap[transientRecords]
+	if !ok {
+		return nil, fmt.Errorf("transient field %s is required", transientRecords)
+	}
+
+	var records DisclosedRecords
+	err = json.Unmarshal(recordsJSON, &records)
+	if err != nil {
+		return nil, fmt.Errorf("transient field %s is not valid JSON: %v", transientRecords, err)
+	}
+
+	result := &ReconciliationResult{Address: address, Discrepancies: []string{}}
+	total := big.NewInt(0)
+
+	// The balance record
+	switch {
+	case records.Balance == "" && hashes.Balance.Hash != "":
+		result.Discrepancies = append(result.Discrepancies, "balance record was not disclosed")
+	case records.Balance != "" && recordHash([]byte(records.Balance)) != hashes.Balance.Hash:
+		result.Discrepancies = append(result.Discrepancies, "balance record does not match the ledger hash")
+	case records.Balance != "":
+		var balance Balance
+		err = json.Unmarshal([]byte(records.Balance), &balance)
+		if err != nil || balance.Address != address {
+			result.Discrepancies = append(result.Discrepancies, "balance record is not a balance of "+address)
+			break
+		}
+		amount, err := parseStoredAmount(balance.Amount)
+		if err != nil {
+			result.Discrepancies = append(result.Discrepancies, fmt.Sprintf("balance record has an invalid amount: %v", err))
+			break
+		}
+		total.Add(total, amount)
+	}
+
+	// Every credit with a public marker must be disclosed, and nothing else
+	expected := map[string]string{}
+	for _, credit := range hashes.Credits {
+		expected[credit.Key] = credit.Hash
+	}
+	for _, creditJSON := range records.Credits {
+		var credit Credit
+		err = json.Unmarshal([]byte(creditJSON), &credit)
+		if err != nil || credit.Address != address {
+			result.Discrepancies = append(result.Discrepancies, "disclosed credit is not a credit of "+address)
+			continue
+		}
+
+		creditKey, err := ctx.GetStub().CreateCompositeKey(creditObjectType, []string{address, credit.TxID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create composite key: %v", err)
+		}
+		ledgerHash, ok := expected[creditKey]
+		if !ok {
+			result.Discrepancies = append(result.Discrepancies, fmt.Sprintf("credit from transaction %s is not on the ledger", credit.TxID))
+			continue
+		}
+		delete(expected, creditKey)
+
+		if recordHash([]byte(creditJSON)) != ledgerHash {
+			result.Discrepancies = append(result.Discrepancies, fmt.Sprintf("credit from transaction %s does not match the ledger hash", credit.TxID))
+			continue
+		}
+		amount, err := parseStoredAmount(credit.Amount)
+		if err != nil {
+			result.Discrepancies = append(result.Discrepancies, fmt.Sprintf("credit from transaction %s has an invalid amount: %v", credit.TxID, err))
+			continue
+		}
+		total.Add(total, amount)
+	}
+	for _, credit := range hashes.Credits {
+		if _, missing := expected[credit.Key]; missing {
+			_, attributes, _ := ctx.GetStub().SplitCompositeKey(credit.Key)
+			result.Discrepancies = append(result.Discrepancies, fmt.Sprintf("credit from transaction %s was not disclosed", attributes[len(attributes)-1]))
+		}
+	}
+
+	result.Balance = total.String()
+	result.Consistent = len(result.Discrepancies) == 0
+	return result, nil
+}
+
+// requireConfidentialMode returns the token metadata, failing unless confidential mode is enabled
+func requireConfidentialMode(ctx contractapi.TransactionContextInterface) (*Token, error) {
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Confidential {
+		return nil, fmt.Errorf("confidential mode is not enabled")
+	}
+	return token, nil
+}
+
+// isConfidential reports whether balances are kept in private collections
+func isConfidential(ctx contractapi.TransactionContextInterface) (bool, error) {
+	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+	if err != nil {
+		return false, fmt.Errorf("failed to read token metadata: %v", err)
+	}
+	if tokenJSON == nil {
+		return false, nil
+	}
+
+	var token Token
+	err = json.Unmarshal(tokenJSON, &token)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal token: %v", err)
+	}
+
+	return token.Confidential, nil
+}
+
+// requireAccountOwner returns the account of an address if the caller holds it, or is an admin,
+// in the organization that owns it, and this peer belongs to that organization, so the balance
+// can be read
+func requireAccountOwner(ctx contractapi.TransactionContextInterface, address string) (*Account, error) {
+	account, err := getAccount(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %s is not registered", address)
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if mspId != account.MSPID {
+		return nil, fmt.Errorf("account %s belongs to %s, not %s", address, account.MSPID, mspId)
+	}
+
+	err = requireAddressHolder(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requirePeerOf(account.MSPID)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// requireAddressHolder fails unless the caller's user ID is the address or the caller is an admin
+func requireAddressHolder(ctx contractapi.TransactionContextInterface, address string) error {
+	if access.RequireAdmin(ctx) == nil {
+		return nil
+	}
+
+	callerId, err := access.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerId != address {
+		return fmt.Errorf("address %s is not held by the caller %s; only its holder or an admin can use it", address, callerId)
+	}
+
+	return nil
+}
+
+// requirePeerOf fails unless this peer belongs to the organization, and so stores its implicit collection
+func requirePeerOf(mspId string) error {
+	peerMSPID, err := shim.GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get peer MSP ID: %v", err)
+	}
+	if peerMSPID != mspId {
+		return fmt.Errorf("confidential balances of %s can only be read on its own peers, not on a %s peer", mspId, peerMSPID)
+	}
+	return nil
+}
+
+// getAccount reads the account of an address, or nil if it is not registered
+func getAccount(ctx contractapi.TransactionContextInterface, address string) (*Account, error) {
+	accountJSON, err := ctx.GetStub().GetState(accountKey(address))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account: %v", err)
+	}
+	if accountJSON == nil {
+		return nil, nil
+	}
+
+	var account Account
+	err = json.Unmarshal(accountJSON, &account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account: %v", err)
+	}
+
+	return &account, nil
+}
+
+// getConfidentialBalance sums the private balance and credits of an account
+func getConfidentialBalance(ctx contractapi.TransactionContextInterface, account *Account) (*big.Int, error) {
+	err := requirePeerOf(account.MSPID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, _, err := readConfidentialRecords(ctx, account)
+	return balance, err
+}
+
+// consolidateBalance folds the credits of an account into its balance and returns the total.
+// The caller writes the new balance.
+func consolidateBalance(ctx contractapi.TransactionContextInterface, account *Account) (*big.Int, error) {
+	balance, creditKeys, err := readConfidentialRecords(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := implicitCollection(account.MSPID)
+	for _, creditKey := range creditKeys {
+		err = ctx.GetStub().DelPrivateData(collection, creditKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete credit: %v", err)
+		}
+		err = ctx.GetStub().DelState(creditKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete credit marker: %v", err)
+		}
+	}
+
+	return balance, nil
+}
+
+// readConfidentialRecords sums the private balance and credits of an account, and returns the credit keys
+func readConfidentialRecords(ctx contractapi.TransactionContextInterface, account *Account) (*big.Int, []string, error) {
+	collection := implicitCollection(account.MSPID)
+
+	total := big.NewInt(0)
+	balanceJSON, err := ctx.GetStub().GetPrivateData(collection, fmt.Sprintf("BALANCE_%s", account.Address))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read confidential balance: %v", err)
+	}
+	if balanceJSON != nil {
+		var balance Balance
+		err = json.Unmarshal(balanceJSON, &balance)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal confidential balance: %v", err)
+		}
+		amount, err := parseStoredAmount(balance.Amount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse confidential balance: %v", err)
+		}
+		total.Add(total, amount)
+	}
+
+	creditKeys, err := getCreditKeys(ctx, account.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, creditKey := range creditKeys {
+		creditJSON, err := ctx.GetStub().GetPrivateData(collection, creditKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read credit: %v", err)
+		}
+		if creditJSON == nil {
+			return nil, nil, fmt.Errorf("credit %s is not available on this peer", creditKey)
+		}
+
+		var credit Credit
+		err = json.Unmarshal(creditJSON, &credit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal credit: %v", err)
+		}
+		amount, err := parseStoredAmount(credit.Amount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse credit: %v", err)
+		}
+		total.Add(total, amount)
+	}
+
+	return total, creditKeys, nil
+}
+
+// putConfidentialBalance writes the private balance of an account
+func putConfidentialBalance(ctx contractapi.TransactionContextInterface, account *Account, amount *big.Int) error {
+	err := requireBalanceWritable(ctx, account.Address)
+	if err != nil {
+		return err
+	}
+
+	salt, err := getSalt(ctx)
+	if err != nil {
+		return err
+	}
+
+	balanceJSON, err := json.Marshal(Balance{Address: account.Address, Amount: amount.String(), Salt: salt})
+	if err != nil {
+		return err
+	}
+
+	collection := implicitCollection(account.MSPID)
+	balanceKey := fmt.Sprintf("BALANCE_%s", account.Address)
+	err = ctx.GetStub().PutPrivateData(collection, balanceKey, balanceJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put confidential balance: %v", err)
+	}
+
+	return setOwnerPolicy(ctx, collection, balanceKey, account.MSPID)
+}
+
+// putCredit pays an amount to an account without reading its balance, which any organization may do.
+// Credits are keyed by transaction, so a transaction credits an address at most once.
+func putCredit(ctx contractapi.TransactionContextInterface, account *Account, amount *big.Int) error {
+	err := requireBalanceWritable(ctx, account.Address)
+	if err != nil {
+		return err
+	}
+
+	salt, err := getSalt(ctx)
+	if err != nil {
+		return err
+	}
+
+	txId := ctx.GetStub().GetTxID()
+	creditKey, err := ctx.GetStub().CreateCompositeKey(creditObjectType, []string{account.Address, txId})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	creditJSON, err := json.Marshal(Credit{Address: account.Address, Amount: amount.String(), TxID: txId, Salt: salt})
+	if err != nil {
+		return err
+	}
+
+	collection := implicitCollection(account.MSPID)
+	err = ctx.GetStub().PutPrivateData(collection, creditKey, creditJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put credit: %v", err)
+	}
+
+	// The public marker lets auditors know the credit exists without learning its amount
+	err = ctx.GetStub().PutState(creditKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put credit marker: %v", err)
+	}
+
+	// Any organization may pay a credit, but only the owner may fold it into the balance
+	err = setOwnerPolicy(ctx, collection, creditKey, account.MSPID)
+	if err != nil {
+		return err
+	}
+
+	return setOwnerPolicy(ctx, "", creditKey, account.MSPID)
+}
+
+// setOwnerPolicy sets a state-based endorsement policy on a key that only the peers of the owning
+// organization can satisfy. An empty collection means the public key.
+func setOwnerPolicy(ctx contractapi.TransactionContextInterface, collection string, key string, mspId string) error {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create endorsement policy: %v", err)
+	}
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, mspId)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to endorsement policy: %v", mspId, err)
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to marshal endorsement policy: %v", err)
+	}
+
+	if collection == "" {
+		err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	} else {
+		err = ctx.GetStub().SetPrivateDataValidationParameter(collection, key, policy)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set endorsement policy of %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// getCreditKeys lists the credits of an address from their public markers
+func getCreditKeys(ctx contractapi.TransactionContextInterface, address string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(creditObjectType, []string{address})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credits: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var creditKeys []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next credit: %v", err)
+		}
+		creditKeys = append(creditKeys, queryResponse.Key)
+	}
+
+	return creditKeys, nil
+}
+
+// getPublicBalance reads the public balance of an address
+func getPublicBalance(ctx contractapi.TransactionContextInterface, address string) (*big.Int, error) {
+	balanceJSON, err := ctx.GetStub().GetState(fmt.Sprintf("BALANCE_%s", address))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance: %v", err)
+	}
+	if balanceJSON == nil {
+		return big.NewInt(0), nil
+	}
+
+	var balance Balance
+	err = json.Unmarshal(balanceJSON, &balance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal balance: %v", err)
+	}
+
+	return parseStoredAmount(balance.Amount)
+}
+
+// getSalt returns the salt sent with the transaction. Without one the hash of a private record
+// could be found by hashing likely amounts.
+func getSalt(ctx contractapi.TransactionContextInterface) (string, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	salt := transientMap[transientSalt]
+	if len(salt) < minSaltLength {
+		return "", fmt.Errorf("transient field %s is required and must be at least %d bytes", transientSalt, minSaltLength)
+	}
+
+	return string(salt), nil
+}
+
+// privateRecordHash returns the ledger hash of a private record
+func privateRecordHash(ctx contractapi.TransactionContextInterface, collection string, key string) (*RecordHash, error) {
+	hash, err := ctx.GetStub().GetPrivateDataHash(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data hash: %v", err)
+	}
+
+	return &RecordHash{Key: key, Hash: hex.EncodeToString(hash)}, nil
+}
+
+// recordHash returns the hex SHA-256 of a record, which is how Fabric hashes private data
+func recordHash(record []byte) string {
+	digest := sha256.Sum256(record)
+	return hex.EncodeToString(digest[:])
+}
+
+// accountKey returns the state key of the account of an address
+func accountKey(address string) string {
+	return fmt.Sprintf("ACCOUNT_%s", address)
+}
+
+// implicitCollection returns the name of an organization's implicit private collection
+func implicitCollection(mspId string) string {
+	return "_implicit_org_" + mspId
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chaincode-common/access"
+	"chaincode-common/events"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Incident controls. Pause halts all token movement; FreezeAccount halts it for one address.
+// Both are enforced by requireBalanceWritable, which every balance write calls: setBalance,
+// putConfidentialBalance and putCredit. A new way to move tokens that writes balances through
+// them cannot skip the checks.
+
+// Reason codes for pausing the token and freezing accounts
+const (
+	ReasonSuspectedFraud = "SUSPECTED_FRAUD"
+	ReasonCompromisedKey = "COMPROMISED_KEY"
+	ReasonLegalOrder     = "LEGAL_ORDER"
+	ReasonInvestigation  = "INVESTIGATION"
+	ReasonMaintenance    = "MAINTENANCE"
+	ReasonOther          = "OTHER"
+)
+
+// validReasonCodes lists the accepted reason codes
+var validReasonCodes = map[string]bool{
+	ReasonSuspectedFraud: true,
+	ReasonCompromisedKey: true,
+	ReasonLegalOrder:     true,
+	ReasonInvestigation:  true,
+	ReasonMaintenance:    true,
+	ReasonOther:          true,
+}
+
+// pauseKey is the state key of the pause status; it only exists while the token is paused
+const pauseKey = "PAUSE_STATUS"
+
+// PauseStatus tells whether token movement is halted
+type PauseStatus struct {
+	Paused     bool   `json:"paused"`
+	ReasonCode string `json:"reasonCode,omitempty" metadata:",optional"`
+	Note       string `json:"note,omitempty" metadata:",optional"`
+	Since      string `json:"since,omitempty" metadata:",optional"` // transaction timestamp of Pause
+}
+
+// FreezeStatus tells whether an address is frozen, stored under FREEZE_<address> while it is
+type FreezeStatus struct {
+	Address    string `json:"address"`
+	Frozen     bool   `json:"frozen"`
+	ReasonCode string `json:"reasonCode,omitempty" metadata:",optional"`
+	Note       string `json:"note,omitempty" metadata:",optional"`
+	Since      string `json:"since,omitempty" metadata:",optional"` // transaction timestamp of FreezeAccount
+}
+
+// Pause halts Mint, Burn, Transfer and every other balance change until Unpause. Only an admin
+// may call it. reasonCode is one of the Reason constants; note is free text.
+func (s *BobCoinContract) Pause(ctx contractapi.TransactionContextInterface, reasonCode string, note string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := getPauseStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Paused {
+		return fmt.Errorf("BobCoin is already paused (%s)", status.ReasonCode)
+	}
+
+	since, err := controlTimestamp(ctx, reasonCode)
+	if err != nil {
+		return err
+	}
+	status = &PauseStatus{Paused: true, ReasonCode: reasonCode, Note: note, Since: since}
+	err = putControlStatus(ctx, pauseKey, status)
+	if err != nil {
+		return err
+	}
+
+	return events.Emit(ctx, &events.Paused{ReasonCode: reasonCode, Note: note})
+}
+
+// Unpause lets tokens move again. Only an admin may call it.
+func (s *BobCoinContract) Unpause(ctx contractapi.TransactionContextInterface) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := getPauseStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if !status.Paused {
+		return fmt.Errorf("BobCoin is not paused")
+	}
+
+	err = ctx.GetStub().DelState(pauseKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete pause status: %v", err)
+	}
+
+	return events.Emit(ctx, &events.Unpaused{})
+}
+
+// GetPauseStatus returns whether token movement is halted and why
+func (s *BobCoinContract) GetPauseStatus(ctx contractapi.TransactionContextInterface) (*PauseStatus, error) {
+	return getPauseStatus(ctx)
+}
+
+// getPauseStatus reads the pause status
+func getPauseStatus(ctx contractapi.TransactionContextInterface) (*PauseStatus, error) {
+	statusJSON, err := ctx.GetStub().GetState(pauseKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pause status: %v", err)
+	}
+	if statusJSON == nil {
+		return &PauseStatus{}, nil
+	}
+
+	var status PauseStatus
+	err = json.Unmarshal(statusJSON, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pause status: %v", err)
+	}
+
+	return &status, nil
+}
+
+// FreezeAccount stops an address from sending or receiving tokens until UnfreezeAccount.
+// Only an admin may call it. reasonCode is one of the Reason constants; note is free text.
+func (s *BobCoinContract) FreezeAccount(ctx contractapi.TransactionContextInterface, address string, reasonCode string, note string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if address == "" {
+		return fmt.Errorf("address is required")
+	}
+
+	status, err := getFreezeStatus(ctx, address)
+	if err != nil {
+		return err
+	}
+	if status.Frozen {
+		return fmt.Errorf("address %s is already frozen (%s)", address, status.ReasonCode)
+	}
+
+	since, err := controlTimestamp(ctx, reasonCode)
+	if err != nil {
+		return err
+	}
+	status = &FreezeStatus{Address: address, Frozen: true, ReasonCode: reasonCode, Note: note, Since: since}
+	err = putControlStatus(ctx, freezeKey(address), status)
+	if err != nil {
+		return err
+	}
+
+	return events.Emit(ctx, &events.AccountFrozen{Address: address, ReasonCode: reasonCode, Note: note})
+}
+
+// UnfreezeAccount lets a frozen address send and receive tokens again. Only an admin may call it.
+func (s *BobCoinContract) UnfreezeAccount(ctx contractapi.TransactionContextInterface, address string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := getFreezeStatus(ctx, address)
+	if err != nil {
+		return err
+	}
+	if !status.Frozen {
+		return fmt.Errorf("address %s is not frozen", address)
+	}
+
+	err = ctx.GetStub().DelState(freezeKey(address))
+	if err != nil {
+		return fmt.Errorf("failed to delete freeze status: %v", err)
+	}
+
+	return events.Emit(ctx, &events.AccountUnfrozen{Address: address})
+}
+
+// GetFreezeStatus returns whether an address is frozen and why
+func (s *BobCoinContract) GetFreezeStatus(ctx contractapi.TransactionContextInterface, address string) (*FreezeStatus, error) {
+	return getFreezeStatus(ctx, address)
+}
+
+// getFreezeStatus reads the freeze status of an address
+func getFreezeStatus(ctx contractapi.TransactionContextInterface, address string) (*FreezeStatus, error) {
+	statusJSON, err := ctx.GetStub().GetState(freezeKey(address))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read freeze status: %v", err)
+	}
+	if statusJSON == nil {
+		return &FreezeStatus{Address: address}, nil
+	}
+
+	var status FreezeStatus
+	err = json.Unmarshal(statusJSON, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal freeze status: %v", err)
+	}
+
+	return &status, nil
+}
+
+// requireBalanceWritable fails if the token is paused or the address is frozen. Every
+// function that writes a balance calls it first.
+func requireBalanceWritable(ctx contractapi.TransactionContextInterface, address string) error {
+	pause, err := getPauseStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if pause.Paused {
+		return fmt.Errorf("BobCoin is paused (%s)", pause.ReasonCode)
+	}
+
+	freeze, err := getFreezeStatus(ctx, address)
+	if err != nil {
+		return err
+	}
+	if freeze.Frozen {
+		return fmt.Errorf("address %s is frozen (%s)", address, freeze.ReasonCode)
+	}
+
+	return nil
+}
+
+// controlTimestamp validates a reason code and returns the transaction timestamp to record
+func controlTimestamp(ctx contractapi.TransactionContextInterface, reasonCode string) (string, error) {
+	if !validReasonCodes[reasonCode] {
+		return "", fmt.Errorf("invalid reason code %q; use one of %s, %s, %s, %s, %s or %s", reasonCode,
+			ReasonSuspectedFraud, ReasonCompromisedKey, ReasonLegalOrder, ReasonInvestigation, ReasonMaintenance, ReasonOther)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return txTimestamp.AsTime().UTC().Format(time.RFC3339), nil
+}
+
+// putControlStatus writes a pause or freeze status
+func putControlStatus(ctx contractapi.TransactionContextInterface, key string, status interface{}) error {
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, statusJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// freezeKey is the state key of an address's freeze status
+func freezeKey(address string) string {
+	return fmt.Sprintf("FREEZE_%s", address)
+}
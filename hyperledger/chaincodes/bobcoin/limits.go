@@ -0,0 +1,554 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"chaincode-common/access"
+	"chaincode-common/events"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Mint limits. Minting is denied by default: a minter needs a mint allowance, which caps
+// what it may mint in any 24 hours, and for a token with a max supply a supply quota, its
+// share of the supply still to be minted. A leaked minter key can therefore only do bounded
+// damage.
+//
+// A mint only reads and writes keys of its own minter: its allowance, its supply quota and
+// its mint records. Mints by different minters never conflict. Two mints by the same minter
+// in one block do, and the later one fails with a phantom or MVCC read conflict; a minter's
+// mints must be retried rather than sent in parallel. Quotas are handed out by an admin,
+// so no mint reads the total supply or a shared counter.
+
+// mintAllowanceWindow is the rolling window mint allowances apply to
+const mintAllowanceWindow = 24 * time.Hour
+
+// mintRecordObjectType is the composite key object type of the mints counted against
+// allowances, keyed by minter, transaction timestamp and transaction ID
+const mintRecordObjectType = "mint"
+
+// supplyQuotaObjectType is the composite key object type of supply quotas, keyed by minter
+const supplyQuotaObjectType = "quota"
+
+// Schema version 3 counted allowance usage under MINT_USAGE_<minterId> and the capped
+// supply under CAPPED_SUPPLY; schema step 4 turns them back into mint records
+const (
+	legacyMintUsageKeyPrefix = "MINT_USAGE_"
+	legacyMintUsageKeyEnd    = "MINT_USAGE`"
+	legacyCappedSupplyKey    = "CAPPED_SUPPLY"
+)
+
+// MintAllowance is the most a minter may mint in any 24 hours, stored under MINT_ALLOWANCE_<minterId>
+type MintAllowance struct {
+	MinterID       string `json:"minterId"`       // <MSP ID>/<certificate common name>
+	DailyAllowance string `json:"dailyAllowance"` // raw units
+}
+
+// MintAllowanceStatus is a minter's allowance and what is left of it
+type MintAllowanceStatus struct {
+	MinterID       string `json:"minterId"`
+	DailyAllowance string `json:"dailyAllowance"`                             // raw units
+	Minted         string `json:"minted"`                                     // raw units minted since windowStart
+	Remaining      string `json:"remaining"`                                  // raw units that may still be minted now
+	WindowStart    string `json:"windowStart"`                                // start of the 24 hours ending at the transaction timestamp
+	SupplyQuota    string `json:"supplyQuota,omitempty" metadata:",optional"` // raw units left of the minter's share of the max supply; empty without a max supply
+}
+
+// legacyMintUsage is the MINT_USAGE_ record of schema version 3
+type legacyMintUsage struct {
+	MinterID   string `json:"minterId"`
+	Minted     string `json:"minted"`
+	LastMintAt string `json:"lastMintAt"`
+}
+
+// SetMintAllowance limits what a minter may mint in any 24 hours. dailyAllowance is in whole
+// tokens; 0 stops the minter from minting. minterId is "<MSP ID>/<certificate common name>",
+// e.g. "Org1MSP/backend@org1.example.com". Only an admin may call it.
+func (s *BobCoinContract) SetMintAllowance(ctx contractapi.TransactionContextInterface, minterId string, dailyAllowance string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if minterId == "" {
+		return fmt.Errorf("minterId is required")
+	}
+
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	allowance, err := token.parseAmount(dailyAllowance)
+	if err != nil {
+		return fmt.Errorf("failed to parse daily allowance: %v", err)
+	}
+
+	allowanceJSON, err := json.Marshal(MintAllowance{MinterID: minterId, DailyAllowance: allowance.String()})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(mintAllowanceKey(minterId), allowanceJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put mint allowance: %v", err)
+	}
+
+	return events.Emit(ctx, &events.MintAllowanceSet{MinterID: minterId, DailyAllowance: token.formatAmount(allowance)})
+}
+
+// RemoveMintAllowance stops a minter from minting and releases its supply quota. Only an admin may call it.
+func (s *BobCoinContract) RemoveMintAllowance(ctx contractapi.TransactionContextInterface, minterId string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowance, err := getMintAllowance(ctx, minterId)
+	if err != nil {
+		return err
+	}
+	if allowance == nil {
+		return fmt.Errorf("minter %s has no mint allowance", minterId)
+	}
+
+	err = ctx.GetStub().DelState(mintAllowanceKey(minterId))
+	if err != nil {
+		return fmt.Errorf("failed to delete mint allowance: %v", err)
+	}
+
+	quotaKey, err := supplyQuotaKey(ctx, minterId)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(quotaKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete supply quota: %v", err)
+	}
+
+	return events.Emit(ctx, &events.MintAllowanceRemoved{MinterID: minterId})
+}
+
+// GetMintAllowance returns a minter's daily allowance, how much of it is left and, for a token
+// with a max supply, what is left of its supply quota
+func (s *BobCoinContract) GetMintAllowance(ctx contractapi.TransactionContextInterface, minterId string) (*MintAllowanceStatus, error) {
+	allowance, err := getMintAllowance(ctx, minterId)
+	if err != nil {
+		return nil, err
+	}
+	if allowance == nil {
+		return nil, fmt.Errorf("minter %s has no mint allowance", minterId)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	windowStart := txTimestamp.AsTime().Add(-mintAllowanceWindow)
+	records, err := getMintRecords(ctx, minterId, windowStart)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := allowanceStatus(allowance, records.minted, windowStart)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token.MaxSupply != "" {
+		quota, err := getSupplyQuota(ctx, minterId)
+		if err != nil {
+			return nil, err
+		}
+		status.SupplyQuota = quota.String()
+	}
+
+	return status, nil
+}
+
+// SetSupplyQuota sets how much of the max supply a minter may still mint. quota is in whole
+// tokens; 0 releases the minter's share. The total supply plus every minter's quota may not
+// exceed the max supply, so mints never need to read the total supply. Burned tokens free up
+// room for new quotas. Only an admin may call it.
+// It reads the supply deltas and every quota, so it fails if a Mint or Burn commits in the
+// same block; retry it on MVCC or phantom read conflicts.
+func (s *BobCoinContract) SetSupplyQuota(ctx contractapi.TransactionContextInterface, minterId string, quota string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if minterId == "" {
+		return fmt.Errorf("minterId is required")
+	}
+
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	if token.MaxSupply == "" {
+		return fmt.Errorf("%s has no max supply; minters need no supply quota", token.Symbol)
+	}
+	quotaAmount, err := token.parseAmount(quota)
+	if err != nil {
+		return fmt.Errorf("failed to parse supply quota: %v", err)
+	}
+
+	maxSupply, err := parseStoredAmount(token.MaxSupply)
+	if err != nil {
+		return fmt.Errorf("failed to parse max supply: %v", err)
+	}
+	supply, err := getTotalSupply(ctx)
+	if err != nil {
+		return err
+	}
+	allocated, err := getAllocatedQuotas(ctx, minterId)
+	if err != nil {
+		return err
+	}
+
+	available := new(big.Int).Sub(maxSupply, supply)
+	available.Sub(available, allocated)
+	if quotaAmount.Cmp(available) > 0 {
+		if available.Sign() < 0 {
+			available.SetInt64(0)
+		}
+		return fmt.Errorf("supply quota would exceed the max supply of %s %s; at most %s can be allocated to minter %s", token.formatAmount(maxSupply), token.Symbol, token.formatAmount(available), minterId)
+	}
+
+	err = putSupplyQuota(ctx, minterId, quotaAmount)
+	if err != nil {
+		return err
+	}
+
+	return events.Emit(ctx, &events.SupplyQuotaSet{MinterID: minterId, Quota: token.formatAmount(quotaAmount)})
+}
+
+// useSupplyQuota charges a mint to the minter's share of the max supply, if the token has one
+func useSupplyQuota(ctx contractapi.TransactionContextInterface, token *Token, minterId string, amount *big.Int) error {
+	if token.MaxSupply == "" {
+		return nil
+	}
+
+	quota, err := getSupplyQuota(ctx, minterId)
+	if err != nil {
+		return err
+	}
+	if amount.Cmp(quota) > 0 {
+		return fmt.Errorf("mint exceeds the supply quota of minter %s; %s %s can still be minted until an admin raises it", minterId, token.formatAmount(quota), token.Symbol)
+	}
+
+	return putSupplyQuota(ctx, minterId, new(big.Int).Sub(quota, amount))
+}
+
+// useMintAllowance charges a mint to the minter's allowance, records it and drops the
+// minter's mint records that have left the window. A minter without an allowance may not mint.
+func useMintAllowance(ctx contractapi.TransactionContextInterface, token *Token, minterId string, amount *big.Int) error {
+	allowance, err := getMintAllowance(ctx, minterId)
+	if err != nil {
+		return err
+	}
+	if allowance == nil {
+		return fmt.Errorf("minter %s has no mint allowance; an admin must grant one with SetMintAllowance", minterId)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	mintTime := txTimestamp.AsTime().UTC()
+	windowStart := mintTime.Add(-mintAllowanceWindow)
+
+	records, err := getMintRecords(ctx, minterId, windowStart)
+	if err != nil {
+		return err
+	}
+
+	// A timestamp before the minter's last mint would move the window back over mints it
+	// has already dropped
+	if records.last != "" && mintRecordTime(mintTime) < records.last {
+		return fmt.Errorf("transaction timestamp %s is before the last mint of minter %s", mintTime.Format(time.RFC3339Nano), minterId)
+	}
+
+	status, err := allowanceStatus(allowance, records.minted, windowStart)
+	if err != nil {
+		return err
+	}
+	remaining, _ := new(big.Int).SetString(status.Remaining, 10)
+	if amount.Cmp(remaining) > 0 {
+		return fmt.Errorf("mint exceeds the daily allowance of minter %s; %s %s can be minted until more of the allowance frees up", minterId, token.formatAmount(remaining), token.Symbol)
+	}
+
+	// The record written below becomes the minter's latest, so every expired one can go
+	for _, key := range records.expiredKeys {
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete mint record: %v", err)
+		}
+	}
+
+	return putMintRecord(ctx, minterId, mintTime, amount)
+}
+
+// mintRecords sums up a minter's mint records
+type mintRecords struct {
+	minted      *big.Int // raw units minted from the window start on
+	expiredKeys []string // keys of the records before the window start
+	last        string   // time of the latest record as formatted by mintRecordTime, empty if there is none
+}
+
+// getMintRecords reads a minter's mint records against the window starting at windowStart
+func getMintRecords(ctx contractapi.TransactionContextInterface, minterId string, windowStart time.Time) (*mintRecords, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(mintRecordObjectType, []string{minterId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint records: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	start := mintRecordTime(windowStart)
+	records := &mintRecords{minted: big.NewInt(0)}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next mint record: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(attributes) != 3 {
+			return nil, fmt.Errorf("invalid mint record key %s", queryResponse.Key)
+		}
+		// Records sort by time, so the last one read is the latest
+		records.last = attributes[1]
+		if attributes[1] < start {
+			records.expiredKeys = append(records.expiredKeys, queryResponse.Key)
+			continue
+		}
+
+		amount, err := parseStoredAmount(string(queryResponse.Value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid mint record %s: %v", queryResponse.Key, err)
+		}
+		records.minted.Add(records.minted, amount)
+	}
+
+	return records, nil
+}
+
+// putMintRecord records a mint against the minter's allowance
+func putMintRecord(ctx contractapi.TransactionContextInterface, minterId string, mintTime time.Time, amount *big.Int) error {
+	recordKey, err := ctx.GetStub().CreateCompositeKey(mintRecordObjectType, []string{minterId, mintRecordTime(mintTime), ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(recordKey, []byte(amount.String()))
+	if err != nil {
+		return fmt.Errorf("failed to put mint record: %v", err)
+	}
+
+	return nil
+}
+
+// getSupplyQuota reads what is left of a minter's supply quota; a minter without one has none
+func getSupplyQuota(ctx contractapi.TransactionContextInterface, minterId string) (*big.Int, error) {
+	quotaKey, err := supplyQuotaKey(ctx, minterId)
+	if err != nil {
+		return nil, err
+	}
+
+	quotaBytes, err := ctx.GetStub().GetState(quotaKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supply quota: %v", err)
+	}
+	if quotaBytes == nil {
+		return big.NewInt(0), nil
+	}
+
+	quota, err := parseStoredAmount(string(quotaBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid supply quota of minter %s: %v", minterId, err)
+	}
+
+	return quota, nil
+}
+
+// putSupplyQuota writes a minter's supply quota, deleting it once it is used up
+func putSupplyQuota(ctx contractapi.TransactionContextInterface, minterId string, quota *big.Int) error {
+	quotaKey, err := supplyQuotaKey(ctx, minterId)
+	if err != nil {
+		return err
+	}
+
+	if quota.Sign() == 0 {
+		err = ctx.GetStub().DelState(quotaKey)
+	} else {
+		err = ctx.GetStub().PutState(quotaKey, []byte(quota.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to put supply quota: %v", err)
+	}
+
+	return nil
+}
+
+// getAllocatedQuotas sums the supply quotas of every minter except exceptMinterId
+func getAllocatedQuotas(ctx contractapi.TransactionContextInterface, exceptMinterId string) (*big.Int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(supplyQuotaObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supply quotas: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	allocated := big.NewInt(0)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next supply quota: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(attributes) != 1 {
+			return nil, fmt.Errorf("invalid supply quota key %s", queryResponse.Key)
+		}
+		if attributes[0] == exceptMinterId {
+			continue
+		}
+
+		quota, err := parseStoredAmount(string(queryResponse.Value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid supply quota of minter %s: %v", attributes[0], err)
+		}
+		allocated.Add(allocated, quota)
+	}
+
+	return allocated, nil
+}
+
+// migrateMintUsage turns the MINT_USAGE_ records of schema version 3 back into mint records
+// and drops CAPPED_SUPPLY. What a minter used on its last day counts against its allowance
+// until 24 hours after its last mint. Minters get no supply quota; an admin sets them.
+func migrateMintUsage(ctx contractapi.TransactionContextInterface) error {
+	resultsIterator, err := ctx.GetStub().GetStateByRange(legacyMintUsageKeyPrefix, legacyMintUsageKeyEnd)
+	if err != nil {
+		return fmt.Errorf("failed to get mint usage: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to get next mint usage: %v", err)
+		}
+
+		var usage legacyMintUsage
+		err = json.Unmarshal(queryResponse.Value, &usage)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal mint usage %s: %v", queryResponse.Key, err)
+		}
+		minted, err := parseStoredAmount(usage.Minted)
+		if err != nil {
+			return fmt.Errorf("invalid mint usage of minter %s: %v", usage.MinterID, err)
+		}
+		lastMintAt, err := time.Parse(time.RFC3339Nano, usage.LastMintAt)
+		if err != nil {
+			return fmt.Errorf("invalid last mint time of minter %s: %v", usage.MinterID, err)
+		}
+
+		if minted.Sign() > 0 {
+			err = putMintRecord(ctx, usage.MinterID, lastMintAt, minted)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to delete mint usage: %v", err)
+		}
+	}
+
+	err = ctx.GetStub().DelState(legacyCappedSupplyKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete capped supply: %v", err)
+	}
+
+	return nil
+}
+
+// allowanceStatus works out what is left of an allowance
+func allowanceStatus(allowance *MintAllowance, minted *big.Int, windowStart time.Time) (*MintAllowanceStatus, error) {
+	dailyAllowance, err := parseStoredAmount(allowance.DailyAllowance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse daily allowance: %v", err)
+	}
+
+	remaining := new(big.Int).Sub(dailyAllowance, minted)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+
+	return &MintAllowanceStatus{
+		MinterID:       allowance.MinterID,
+		DailyAllowance: allowance.DailyAllowance,
+		Minted:         minted.String(),
+		Remaining:      remaining.String(),
+		WindowStart:    windowStart.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// getMintAllowance reads a minter's allowance, or nil if the minter has none
+func getMintAllowance(ctx contractapi.TransactionContextInterface, minterId string) (*MintAllowance, error) {
+	allowanceJSON, err := ctx.GetStub().GetState(mintAllowanceKey(minterId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mint allowance: %v", err)
+	}
+	if allowanceJSON == nil {
+		return nil, nil
+	}
+
+	var allowance MintAllowance
+	err = json.Unmarshal(allowanceJSON, &allowance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mint allowance: %v", err)
+	}
+
+	return &allowance, nil
+}
+
+// getMinterID identifies the caller as "<MSP ID>/<certificate common name>"
+func getMinterID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client certificate: %v", err)
+	}
+	if cert == nil || cert.Subject.CommonName == "" {
+		return "", fmt.Errorf("client certificate has no common name")
+	}
+
+	return mspId + "/" + cert.Subject.CommonName, nil
+}
+
+// mintRecordTime formats a time so mint record keys sort by it
+func mintRecordTime(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+// mintAllowanceKey is the state key of a minter's allowance
+func mintAllowanceKey(minterId string) string {
+	return fmt.Sprintf("MINT_ALLOWANCE_%s", minterId)
+}
+
+// supplyQuotaKey is the state key of a minter's supply quota
+func supplyQuotaKey(ctx contractapi.TransactionContextInterface, minterId string) (string, error) {
+	quotaKey, err := ctx.GetStub().CreateCompositeKey(supplyQuotaObjectType, []string{minterId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return quotaKey, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"chaincode-common/access"
+	"chaincode-common/events"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Bounds on token metadata
+const (
+	maxTokenNameLength = 64
+	maxTokenDecimals   = 36
+)
+
+// tokenSymbolPattern is what a symbol may look like, e.g. BOB
+var tokenSymbolPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{0,10}$`)
+
+// UpdateTokenMetadata changes the name and symbol of the token. An empty value leaves that
+// field unchanged. Decimals and the maximum supply cannot change once tokens exist.
+func (s *BobCoinContract) UpdateTokenMetadata(ctx contractapi.TransactionContextInterface, name string, symbol string) (*Token, error) {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		token.Name = name
+	}
+	if symbol != "" {
+		token.Symbol = symbol
+	}
+	err = validateTokenMetadata(token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putTokenMetadata(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = events.Emit(ctx, &events.TokenMetadataUpdated{Name: token.Name, Symbol: token.Symbol})
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the supply the way GetTokenInfo does
+	supply, err := getTotalSupply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token.TotalSupply = supply.String()
+
+	return token, nil
+}
+
+// validateTokenMetadata checks the name, symbol and decimals of a token
+func validateTokenMetadata(token *Token) error {
+	if token.Name == "" {
+		return fmt.Errorf("token name is required")
+	}
+	if !utf8.ValidString(token.Name) || utf8.RuneCountInString(token.Name) > maxTokenNameLength {
+		return fmt.Errorf("token name must be valid UTF-8 of at most %d characters", maxTokenNameLength)
+	}
+	if !tokenSymbolPattern.MatchString(token.Symbol) {
+		return fmt.Errorf("token symbol %q must be 1 to 11 upper case letters and digits, starting with a letter", token.Symbol)
+	}
+	if token.Decimals < 0 || token.Decimals > maxTokenDecimals {
+		return fmt.Errorf("decimals must be between 0 and %d", maxTokenDecimals)
+	}
+
+	return nil
+}
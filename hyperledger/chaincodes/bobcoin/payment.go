@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"chaincode-common/access"
+	"chaincode-common/events"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Bounds on payment references and memos
+const (
+	maxReferenceLength = 128
+	maxMemoLength      = 256
+)
+
+// paymentReferenceIndex indexes payments by reference and transaction ID
+const paymentReferenceIndex = "reference~tx"
+
+// Payment is a transfer made with TransferWithMemo, stored under PAYMENT_<txId>.
+// The reference and memo are public, like the rest of the transfer.
+type Payment struct {
+	TxID      string `json:"txId"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Amount    string `json:"amount"` // whole tokens
+	Reference string `json:"reference"`
+	Memo      string `json:"memo,omitempty" metadata:",optional"`
+	Timestamp string `json:"timestamp"`
+}
+
+// TransferWithMemo pays an address from the caller's own address, tagged with a reference such
+// as an invoice or milestone ID and an optional memo. The caller's address is its userId
+// attribute, or its enrollment ID if it has none. GetPaymentsByReference finds the payment later.
+func (s *BobCoinContract) TransferWithMemo(ctx contractapi.TransactionContextInterface, to string, amount string, reference string, memo string) (*Payment, error) {
+	if reference == "" {
+		return nil, fmt.Errorf("reference is required")
+	}
+	if !utf8.ValidString(reference) || utf8.RuneCountInString(reference) > maxReferenceLength {
+		return nil, fmt.Errorf("reference must be valid UTF-8 of at most %d characters", maxReferenceLength)
+	}
+	if !utf8.ValidString(memo) || utf8.RuneCountInString(memo) > maxMemoLength {
+		return nil, fmt.Errorf("memo must be valid UTF-8 of at most %d characters", maxMemoLength)
+	}
+
+	from, err := access.GetCallerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, transferAmount, err := s.transfer(ctx, from, to, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	payment := Payment{
+		TxID:      ctx.GetStub().GetTxID(),
+		From:      from,
+		To:        to,
+		Amount:    token.formatAmount(transferAmount),
+		Reference: reference,
+		Memo:      memo,
+		Timestamp: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	paymentJSON, err := json.Marshal(payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payment: %v", err)
+	}
+	err = ctx.GetStub().PutState(paymentKey(payment.TxID), paymentJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put payment: %v", err)
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(paymentReferenceIndex, []string{reference, payment.TxID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return nil, fmt.Errorf("failed to put %s index: %v", paymentReferenceIndex, err)
+	}
+
+	// The event carries the reference so accounting can match it without querying
+	err = events.Emit(ctx, &events.Transfer{
+		From:      payment.From,
+		To:        payment.To,
+		Amount:    payment.Amount,
+		Reference: payment.Reference,
+		Memo:      payment.Memo,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// GetPayment returns the payment made by a TransferWithMemo transaction
+func (s *BobCoinContract) GetPayment(ctx contractapi.TransactionContextInterface, txId string) (*Payment, error) {
+	paymentJSON, err := ctx.GetStub().GetState(paymentKey(txId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment: %v", err)
+	}
+	if paymentJSON == nil {
+		return nil, fmt.Errorf("payment %s does not exist", txId)
+	}
+
+	var payment Payment
+	err = json.Unmarshal(paymentJSON, &payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payment: %v", err)
+	}
+
+	return &payment, nil
+}
+
+// GetPaymentsByReference returns every payment made with a reference, e.g. all payments of an invoice
+func (s *BobCoinContract) GetPaymentsByReference(ctx contractapi.TransactionContextInterface, reference string) ([]*Payment, error) {
+	if reference == "" {
+		return nil, fmt.Errorf("reference is required")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(paymentReferenceIndex, []string{reference})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	payments := []*Payment{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next payment: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || len(attributes) != 2 {
+			return nil, fmt.Errorf("invalid %s index key %s", paymentReferenceIndex, queryResponse.Key)
+		}
+
+		payment, err := s.GetPayment(ctx, attributes[1])
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
+
+// paymentKey is the state key of a payment
+func paymentKey(txId string) string {
+	return fmt.Sprintf("PAYMENT_%s", txId)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"chaincode-common/access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The total supply is the compacted supply in TOKEN_METADATA plus one delta record per
+// Mint or Burn, keyed by transaction ID. Writers only add keys, so concurrent mints do
+// not read or write a shared key; readers aggregate the deltas, and CompactSupply folds
+// them back into TOKEN_METADATA.
+
+// supplyDeltaObjectType is the composite key object type of supply delta records
+const supplyDeltaObjectType = "supply"
+
+// maxSupplyCompaction bounds the number of deltas one CompactSupply call folds in
+const maxSupplyCompaction = 1000
+
+// SupplyCompaction reports what one CompactSupply call did
+type SupplyCompaction struct {
+	Compacted   int    `json:"compacted"`   // number of deltas folded in
+	TotalSupply string `json:"totalSupply"` // compacted supply after the call
+	Done        bool   `json:"done"`        // false if deltas are left; call again
+}
+
+// CompactSupply folds supply delta records into the compacted supply in TOKEN_METADATA.
+// It reads the whole delta range, so it fails if a Mint or Burn commits in the same block;
+// run it periodically at a quiet time and retry on MVCC conflicts.
+func (s *BobCoinContract) CompactSupply(ctx contractapi.TransactionContextInterface) (*SupplyCompaction, error) {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getTokenMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	supply, err := parseStoredAmount(token.TotalSupply)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compacted supply: %v", err)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(supplyDeltaObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supply deltas: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	result := &SupplyCompaction{Done: true}
+	for resultsIterator.HasNext() {
+		if result.Compacted == maxSupplyCompaction {
+			result.Done = false
+			break
+		}
+
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next supply delta: %v", err)
+		}
+
+		delta, ok := new(big.Int).SetString(string(queryResponse.Value), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid supply delta %s", string(queryResponse.Value))
+		}
+		supply.Add(supply, delta)
+
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete supply delta: %v", err)
+		}
+		result.Compacted++
+	}
+
+	token.TotalSupply = supply.String()
+	err = putTokenMetadata(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TotalSupply = token.TotalSupply
+	return result, nil
+}
+
+// putSupplyDelta records a change of the total supply made by this transaction
+func putSupplyDelta(ctx contractapi.TransactionContextInterface, delta *big.Int) error {
+	deltaKey, err := ctx.GetStub().CreateCompositeKey(supplyDeltaObjectType, []string{ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(deltaKey, []byte(delta.String()))
+	if err != nil {
+		return fmt.Errorf("failed to put supply delta: %v", err)
+	}
+
+	return nil
+}
+
+// getTotalSupply adds the supply deltas to the compacted supply
+func getTotalSupply(ctx contractapi.TransactionContextInterface) (*big.Int, error) {
+	supply := big.NewInt(0)
+
+	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token metadata: %v", err)
+	}
+	if tokenJSON != nil {
+		var token Token
+		err = json.Unmarshal(tokenJSON, &token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+		}
+		supply, err = parseStoredAmount(token.TotalSupply)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compacted supply: %v", err)
+		}
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(supplyDeltaObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supply deltas: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next supply delta: %v", err)
+		}
+
+		delta, ok := new(big.Int).SetString(string(queryResponse.Value), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid supply delta %s", string(queryResponse.Value))
+		}
+		supply.Add(supply, delta)
+	}
+
+	return supply, nil
+}
+
+// getTokenMetadata reads the token metadata as stored, with the compacted supply
+func getTokenMetadata(ctx contractapi.TransactionContextInterface) (*Token, error) {
+	tokenJSON, err := ctx.GetStub().GetState("TOKEN_METADATA")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token metadata: %v", err)
+	}
+	if tokenJSON == nil {
+		return nil, fmt.Errorf("token metadata does not exist. Initialize first")
+	}
+
+	var token Token
+	err = json.Unmarshal(tokenJSON, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+	}
+
+	return &token, nil
+}
+
+// putTokenMetadata writes the token metadata
+func putTokenMetadata(ctx contractapi.TransactionContextInterface, token *Token) error {
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState("TOKEN_METADATA", tokenJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update token metadata: %v", err)
+	}
+
+	return nil
+}
@@ -12,7 +12,11 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"chaincode-common/access"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
 )
 
 // CertificateContract provides functions for managing certificates
@@ -20,71 +24,44 @@ type CertificateContract struct {
 	contractapi.Contract
 }
 
-// Certificate represents a certificate stored on the blockchain
-type Certificate struct {
-	CertificateID   string `json:"certificateId"`
-	ProjectID       string `json:"projectId"`
-	ContractID      string `json:"contractId,omitempty"`
-	MilestoneID     string `json:"milestoneId,omitempty"`
-	IPFSHash        string `json:"ipfsHash"`
-	TransactionHash string `json:"transactionHash"`
-	FreelancerID    string `json:"freelancerId"`
-	ClientID        string `json:"clientId"`
-	Amount          string `json:"amount"`
-	Timestamp       string `json:"timestamp"`
-	Status          string `json:"status"`
-	CertificateType string `json:"certificateType"` // "CONTRACT", "MILESTONE"
-	IPFSGroupID     string `json:"ipfsGroupId"`     // IPFS group this certificate belongs to
-}
-
-// Project represents a project stored on the blockchain
-type Project struct {
-	ProjectID      string   `json:"projectId"`
-	Title          string   `json:"title"`
-	Description    string   `json:"description"`
-	Category       string   `json:"category"`
-	ClientID       string   `json:"clientId"`
-	TotalBudget    string   `json:"totalBudget"`
-	Deadline       string   `json:"deadline,omitempty"`
-	SkillsRequired []string `json:"skillsRequired,omitempty"`
-	IPFSHash       string   `json:"ipfsHash"`
-	IPFSGroupID    string   `json:"ipfsGroupId"` // IPFS group created for this project
-	RegisteredAt   string   `json:"registeredAt"`
-	Status         string   `json:"status"`
-}
-
-// IPFSGroup represents an IPFS group for project collaboration
-type IPFSGroup struct {
-	GroupID      string   `json:"groupId"`
-	ProjectID   string   `json:"projectId"`
-	IPFSHash     string   `json:"ipfsHash"`     // IPFS hash of the group
-	Members      []string `json:"members"`       // List of member IDs (client, freelancers)
-	CreatedAt    string   `json:"createdAt"`
-	UpdatedAt    string   `json:"updatedAt"`
-}
+// Record types are shared with the compatibility layer in the model package
+type (
+	Certificate = model.Certificate
+	Project     = model.Project
+	IPFSGroup   = model.IPFSGroup
+)
 
 // RegisterProject creates a new project record and creates an IPFS group
-// Args: projectId, title, description, category, clientId, totalBudget, deadline, skillsRequired (JSON), ipfsHash, ipfsGroupHash
-func (s *CertificateContract) RegisterProject(ctx contractapi.TransactionContextInterface) error {
-	args := ctx.GetStub().GetStringArgs()
-	if len(args) != 11 {
-		return fmt.Errorf("incorrect number of arguments. Expecting 10, got %d", len(args)-1)
-	}
-
-	projectId := args[1]
-	title := args[2]
-	description := args[3]
-	category := args[4]
-	clientId := args[5]
-	totalBudget := args[6]
-	deadline := args[7]
-	skillsRequiredJSON := args[8]
-	ipfsHash := args[9]
-	ipfsGroupHash := args[10] // IPFS hash of the created group
-
-	// Validate required fields
-	if projectId == "" || title == "" || ipfsHash == "" || ipfsGroupHash == "" {
-		return fmt.Errorf("projectId, title, ipfsHash, and ipfsGroupHash are required")
+// The input is a single JSON object validated against the ProjectInput schema;
+// the positional argument form is still accepted, see positional.go
+func (s *CertificateContract) RegisterProject(ctx contractapi.TransactionContextInterface, input ProjectInput) error {
+	projectId := input.ProjectID
+	title := input.Title
+	description := input.Description
+	category := input.Category
+	clientId := input.ClientID
+	totalBudget := input.TotalBudget
+	deadline := input.Deadline
+	skillsRequired := input.SkillsRequired
+	ipfsHash := input.IPFSHash
+	ipfsGroupHash := input.IPFSGroupHash // IPFS hash of the created group
+
+	// Validate required fields; only the web registry's positional form, which had no
+	// ipfsGroupHash argument, may create a project without a group hash
+	if projectId == "" || title == "" || ipfsHash == "" {
+		return fmt.Errorf("projectId, title, and ipfsHash are required")
+	}
+	if ipfsGroupHash == "" && !hasNoGroupHashArgument(ctx) {
+		return fmt.Errorf("ipfsGroupHash is required")
+	}
+
+	// Sensitive details may be sent in the transient map instead of the public input
+	privateDetails, err := getProjectPrivateInput(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	if privateDetails != nil && (description != "" || totalBudget != "" || len(skillsRequired) > 0) {
+		return fmt.Errorf("description, totalBudget and skillsRequired must not be sent publicly when transient field %s is set", transientProjectPrivate)
 	}
 
 	// Check if project already exists
@@ -96,15 +73,6 @@ func (s *CertificateContract) RegisterProject(ctx contractapi.TransactionContext
 		return fmt.Errorf("project %s already exists", projectId)
 	}
 
-	// Parse skills required
-	var skillsRequired []string
-	if skillsRequiredJSON != "" && skillsRequiredJSON != "[]" {
-		err = json.Unmarshal([]byte(skillsRequiredJSON), &skillsRequired)
-		if err != nil {
-			skillsRequired = []string{}
-		}
-	}
-
 	// Get transaction timestamp
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
@@ -112,7 +80,7 @@ func (s *CertificateContract) RegisterProject(ctx contractapi.TransactionContext
 	}
 
 	// Create IPFS group with client as initial member
-	groupId := "group:" + projectId
+	groupId := model.GroupID(projectId)
 	group := IPFSGroup{
 		GroupID:   groupId,
 		ProjectID: projectId,
@@ -133,20 +101,36 @@ func (s *CertificateContract) RegisterProject(ctx contractapi.TransactionContext
 		return fmt.Errorf("failed to put group to state: %v", err)
 	}
 
+	// Keep private details in the collection and only their hash on the public ledger
+	privateDetailsHash := ""
+	if privateDetails != nil {
+		privateDetailsHash, err = putProjectPrivateDetails(ctx, privateDetails)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Store the budget as a number too, so budget queries compare it numerically
+	budgetCents := model.ProjectBudgetCents(totalBudget)
+
 	// Create project object
 	project := Project{
+		DocType:        projectDocType,
 		ProjectID:      projectId,
 		Title:          title,
 		Description:    description,
 		Category:       category,
 		ClientID:       clientId,
 		TotalBudget:    totalBudget,
+		BudgetCents:    budgetCents,
 		Deadline:       deadline,
 		SkillsRequired: skillsRequired,
 		IPFSHash:       ipfsHash,
 		IPFSGroupID:    groupId,
 		RegisteredAt:   txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
 		Status:         "open",
+
+		PrivateDetailsHash: privateDetailsHash,
 	}
 
 	projectJSON, err = json.Marshal(project)
@@ -160,6 +144,12 @@ func (s *CertificateContract) RegisterProject(ctx contractapi.TransactionContext
 		return fmt.Errorf("failed to put project to state: %v", err)
 	}
 
+	// Index by category and skills so searches also work on LevelDB
+	err = putProjectIndexes(ctx, &project)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -174,32 +164,62 @@ func (s *CertificateContract) GetProject(ctx contractapi.TransactionContextInter
 		return nil, fmt.Errorf("project %s does not exist", projectId)
 	}
 
-	var project Project
-	err = json.Unmarshal(projectJSON, &project)
+	// Projects written by the web registry are normalized on read
+	project, _, err := model.DecodeProject(projectJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal project: %v", err)
+		return nil, err
 	}
 
-	return &project, nil
+	return project, nil
+}
+
+// UpdateProjectStatus updates the status of a project
+// Status must be one of: open, in_progress, completed, cancelled
+// Only the project's client or an admin may change it; completing a project opens it for reviews
+func (s *CertificateContract) UpdateProjectStatus(ctx contractapi.TransactionContextInterface, projectId string, status string) error {
+	switch status {
+	case "open", "in_progress", "completed", "cancelled":
+	default:
+		return fmt.Errorf("invalid project status %s", status)
+	}
+
+	project, err := s.GetProject(ctx, projectId)
+	if err != nil {
+		return err
+	}
+
+	if access.RequireAdmin(ctx) != nil {
+		callerId, err := access.GetCallerID(ctx)
+		if err != nil {
+			return err
+		}
+		if project.ClientID == "" || callerId != project.ClientID {
+			return fmt.Errorf("only the client of project %s or an admin can update its status", projectId)
+		}
+	}
+
+	project.Status = status
+
+	projectJSON, err := json.Marshal(project)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %v", err)
+	}
+
+	return ctx.GetStub().PutState("project:"+projectId, projectJSON)
 }
 
 // RegisterContractCertificate registers a contract certificate when freelancer signs contract
-// This also adds the freelancer to the IPFS group
-// Args: certificateId, projectId, contractId, ipfsHash, transactionHash, freelancerId, clientId, amount
-func (s *CertificateContract) RegisterContractCertificate(ctx contractapi.TransactionContextInterface) error {
-	args := ctx.GetStub().GetStringArgs()
-	if len(args) != 9 {
-		return fmt.Errorf("incorrect number of arguments. Expecting 8, got %d", len(args)-1)
-	}
-
-	certificateId := args[1]
-	projectId := args[2]
-	contractId := args[3]
-	ipfsHash := args[4]
-	transactionHash := args[5]
-	freelancerId := args[6]
-	clientId := args[7]
-	amount := args[8]
+// This also adds the freelancer to the IPFS group, once escrow confirms the contract's parties
+// The input is a single JSON object validated against the ContractCertificateInput schema
+func (s *CertificateContract) RegisterContractCertificate(ctx contractapi.TransactionContextInterface, input ContractCertificateInput) error {
+	certificateId := input.CertificateID
+	projectId := input.ProjectID
+	contractId := input.ContractID
+	ipfsHash := input.IPFSHash
+	transactionHash := input.TransactionHash
+	freelancerId := input.FreelancerID
+	clientId := input.ClientID
+	amount := input.Amount
 
 	// Validate required fields
 	if certificateId == "" || projectId == "" || contractId == "" || ipfsHash == "" {
@@ -212,6 +232,31 @@ func (s *CertificateContract) RegisterContractCertificate(ctx contractapi.Transa
 		return fmt.Errorf("failed to get project: %v", err)
 	}
 
+	// Group membership unlocks reviews, so only the parties of the escrow contract may join.
+	// When escrow issues the certificate from the first LockFunds it fills them in from its own
+	// contract record; any other caller must be the project's client or an admin and is checked
+	// against escrow
+	fromLock, err := isEscrowLockProposal(ctx, contractId)
+	if err != nil {
+		return err
+	}
+	if !fromLock {
+		if access.RequireAdmin(ctx) != nil {
+			callerId, err := access.GetCallerID(ctx)
+			if err != nil {
+				return err
+			}
+			if project.ClientID == "" || callerId != project.ClientID {
+				return fmt.Errorf("only the client of project %s or an admin can register its contract certificates", projectId)
+			}
+		}
+
+		err = verifyContractParties(ctx, projectId, contractId, freelancerId, clientId)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Get IPFS group
 	groupId := project.IPFSGroupID
 	groupJSON, err := ctx.GetStub().GetState(groupId)
@@ -258,9 +303,9 @@ func (s *CertificateContract) RegisterContractCertificate(ctx contractapi.Transa
 	}
 
 	// Check if certificate already exists
-	certificateJSON, err := ctx.GetStub().GetState(certificateId)
+	certificateJSON, _, err := getCertificateState(ctx, certificateId)
 	if err != nil {
-		return fmt.Errorf("failed to read certificate: %v", err)
+		return err
 	}
 	if certificateJSON != nil {
 		return fmt.Errorf("certificate %s already exists", certificateId)
@@ -284,17 +329,35 @@ func (s *CertificateContract) RegisterContractCertificate(ctx contractapi.Transa
 		Amount:         amount,
 		Timestamp:      txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
 		Status:          "active",
-		CertificateType: "CONTRACT",
+		CertificateType: model.CertificateTypeContract,
 		IPFSGroupID:    groupId,
 	}
 
+	// Record the validity window, if any
+	err = setValidity(&certificate, input.ValidFrom, input.ValidUntil, txTimestamp.AsTime())
+	if err != nil {
+		return err
+	}
+
+	// Record who issued it
+	err = setIssuer(ctx, &certificate)
+	if err != nil {
+		return err
+	}
+
+	// Validate the CID and record the document digest
+	err = setContentInfo(&certificate, input.ContentSHA256)
+	if err != nil {
+		return err
+	}
+
 	certificateJSON, err = json.Marshal(certificate)
 	if err != nil {
 		return fmt.Errorf("failed to marshal certificate: %v", err)
 	}
 
 	// Save certificate to state
-	err = ctx.GetStub().PutState(certificateId, certificateJSON)
+	err = ctx.GetStub().PutState(certificateKey(certificateId), certificateJSON)
 	if err != nil {
 		return fmt.Errorf("failed to put certificate to state: %v", err)
 	}
@@ -319,30 +382,34 @@ func (s *CertificateContract) RegisterContractCertificate(ctx contractapi.Transa
 		return fmt.Errorf("failed to put group-certificate relationship: %v", err)
 	}
 
+	// Index by freelancer and client for portfolio queries
+	err = putCertificatePartyIndexes(ctx, &certificate)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // RegisterMilestoneCertificate registers a milestone certificate
-// Args: certificateId, projectId, contractId, milestoneId, ipfsHash, transactionHash, freelancerId, clientId, amount
-func (s *CertificateContract) RegisterMilestoneCertificate(ctx contractapi.TransactionContextInterface) error {
-	args := ctx.GetStub().GetStringArgs()
-	if len(args) != 10 {
-		return fmt.Errorf("incorrect number of arguments. Expecting 9, got %d", len(args)-1)
-	}
-
-	certificateId := args[1]
-	projectId := args[2]
-	contractId := args[3]
-	milestoneId := args[4]
-	ipfsHash := args[5]
-	transactionHash := args[6]
-	freelancerId := args[7]
-	clientId := args[8]
-	amount := args[9]
+// The milestone must be RELEASED in the escrow chaincode for the same amount, freelancer and
+// client, and may have only one certificate; transactionHash is filled with the release
+// transaction ID and may be left empty
+// The input is a single JSON object validated against the MilestoneCertificateInput schema
+func (s *CertificateContract) RegisterMilestoneCertificate(ctx contractapi.TransactionContextInterface, input MilestoneCertificateInput) error {
+	certificateId := input.CertificateID
+	projectId := input.ProjectID
+	contractId := input.ContractID
+	milestoneId := input.MilestoneID
+	ipfsHash := input.IPFSHash
+	transactionHash := input.TransactionHash
+	freelancerId := input.FreelancerID
+	clientId := input.ClientID
+	amount := input.Amount
 
 	// Validate required fields
-	if certificateId == "" || projectId == "" || milestoneId == "" || ipfsHash == "" {
-		return fmt.Errorf("certificateId, projectId, milestoneId, and ipfsHash are required")
+	if certificateId == "" || projectId == "" || contractId == "" || milestoneId == "" || ipfsHash == "" {
+		return fmt.Errorf("certificateId, projectId, contractId, milestoneId, and ipfsHash are required")
 	}
 
 	// Get project to retrieve IPFS group
@@ -351,10 +418,41 @@ func (s *CertificateContract) RegisterMilestoneCertificate(ctx contractapi.Trans
 		return fmt.Errorf("failed to get project: %v", err)
 	}
 
+	// Confirm the payment with the escrow chaincode. When escrow issues the certificate from
+	// ReleaseMilestone the release happens in this same transaction, which is the proof itself,
+	// and escrow fills in the parties from its own contract record
+	fromRelease, err := isEscrowReleaseProposal(ctx, contractId, milestoneId)
+	if err != nil {
+		return err
+	}
+
+	releaseTxId := ctx.GetStub().GetTxID()
+	if !fromRelease {
+		releaseTxId, err = verifyMilestoneRelease(ctx, projectId, contractId, milestoneId, freelancerId, clientId, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A released milestone earns one certificate
+	existingId, err := getMilestoneCertificateID(ctx, contractId, milestoneId)
+	if err != nil {
+		return err
+	}
+	if existingId != "" {
+		return fmt.Errorf("milestone %s of escrow contract %s already has certificate %s", milestoneId, contractId, existingId)
+	}
+	if releaseTxId != "" {
+		if transactionHash != "" && transactionHash != releaseTxId {
+			return fmt.Errorf("transactionHash %s does not match escrow release transaction %s", transactionHash, releaseTxId)
+		}
+		transactionHash = releaseTxId
+	}
+
 	// Check if certificate already exists
-	certificateJSON, err := ctx.GetStub().GetState(certificateId)
+	certificateJSON, _, err := getCertificateState(ctx, certificateId)
 	if err != nil {
-		return fmt.Errorf("failed to read certificate: %v", err)
+		return err
 	}
 	if certificateJSON != nil {
 		return fmt.Errorf("certificate %s already exists", certificateId)
@@ -379,17 +477,35 @@ func (s *CertificateContract) RegisterMilestoneCertificate(ctx contractapi.Trans
 		Amount:          amount,
 		Timestamp:       txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
 		Status:           "active",
-		CertificateType: "MILESTONE",
+		CertificateType: model.CertificateTypeMilestone,
 		IPFSGroupID:     project.IPFSGroupID,
 	}
 
+	// Record the validity window, if any
+	err = setValidity(&certificate, input.ValidFrom, input.ValidUntil, txTimestamp.AsTime())
+	if err != nil {
+		return err
+	}
+
+	// Record who issued it
+	err = setIssuer(ctx, &certificate)
+	if err != nil {
+		return err
+	}
+
+	// Validate the CID and record the document digest
+	err = setContentInfo(&certificate, input.ContentSHA256)
+	if err != nil {
+		return err
+	}
+
 	certificateJSON, err = json.Marshal(certificate)
 	if err != nil {
 		return fmt.Errorf("failed to marshal certificate: %v", err)
 	}
 
 	// Save certificate to state
-	err = ctx.GetStub().PutState(certificateId, certificateJSON)
+	err = ctx.GetStub().PutState(certificateKey(certificateId), certificateJSON)
 	if err != nil {
 		return fmt.Errorf("failed to put certificate to state: %v", err)
 	}
@@ -414,37 +530,45 @@ func (s *CertificateContract) RegisterMilestoneCertificate(ctx contractapi.Trans
 		return fmt.Errorf("failed to put group-certificate relationship: %v", err)
 	}
 
-	return nil
+	// Index by freelancer and client for portfolio queries
+	err = putCertificatePartyIndexes(ctx, &certificate)
+	if err != nil {
+		return err
+	}
+
+	return putMilestoneCertificateID(ctx, &certificate)
 }
 
 // GetCertificate returns the certificate stored in the world state with given id
 func (s *CertificateContract) GetCertificate(ctx contractapi.TransactionContextInterface, certificateId string) (*Certificate, error) {
-	certificateJSON, err := ctx.GetStub().GetState(certificateId)
+	certificateJSON, _, err := getCertificateState(ctx, certificateId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
 	}
 
 	if certificateJSON == nil {
 		return nil, fmt.Errorf("certificate %s does not exist", certificateId)
 	}
 
-	var certificate Certificate
-	err = json.Unmarshal(certificateJSON, &certificate)
+	// Certificates written by the web registry are normalized on read
+	certificate, _, err := model.DecodeCertificate(certificateJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal certificate: %v", err)
+		return nil, err
 	}
 
-	return &certificate, nil
+	return certificate, nil
 }
 
-// VerifyCertificate verifies if a certificate exists and matches the provided IPFS hash
-func (s *CertificateContract) VerifyCertificate(ctx contractapi.TransactionContextInterface, certificateId string, ipfsHash string) (bool, error) {
+// VerifyCertificate verifies if a certificate exists, matches the provided IPFS hash and is
+// within its validity window at the transaction timestamp
+// A CID in another version or multibase encoding that addresses the same content also matches
+func (s *CertificateContract) VerifyCertificate(ctx contractapi.TransactionContextInterface, certificateId string, ipfsHash string) (*CertificateVerification, error) {
 	certificate, err := s.GetCertificate(ctx, certificateId)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return certificate.IPFSHash == ipfsHash, nil
+	return verifyCertificate(ctx, certificate, ipfsHash)
 }
 
 // GetCertificatesByProject returns all certificates for a given project
@@ -552,33 +676,28 @@ func (s *CertificateContract) GetCertificatesByGroup(ctx contractapi.Transaction
 }
 
 // GetAllCertificates returns all certificates in the world state
+// Certificates are listed from the cert: key range; ones still stored under their bare ID
+// are not listed until the ledger is upgraded with UpgradeSchema or MigrateState
 func (s *CertificateContract) GetAllCertificates(ctx contractapi.TransactionContextInterface) ([]*Certificate, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByRange(certificateKeyPrefix, certificateKeyRangeEnd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get state by range: %v", err)
 	}
 	defer resultsIterator.Close()
 
-	var certificates []*Certificate
+	certificates := []*Certificate{}
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get next: %v", err)
 		}
 
-		// Skip composite keys, project keys, and group keys
-		if len(queryResponse.Key) >= 8 && (queryResponse.Key[0:8] == "project~" || queryResponse.Key[0:8] == "project:" || queryResponse.Key[0:6] == "group:") {
-			continue
-		}
-
-		var certificate Certificate
-		err = json.Unmarshal(queryResponse.Value, &certificate)
+		certificate, _, err := model.DecodeCertificate(queryResponse.Value)
 		if err != nil {
-			// Skip if not a certificate
-			continue
+			return nil, fmt.Errorf("failed to decode %s: %v", queryResponse.Key, err)
 		}
 
-		certificates = append(certificates, &certificate)
+		certificates = append(certificates, certificate)
 	}
 
 	return certificates, nil
@@ -598,7 +717,7 @@ func (s *CertificateContract) UpdateCertificateStatus(ctx contractapi.Transactio
 		return fmt.Errorf("failed to marshal certificate: %v", err)
 	}
 
-	return ctx.GetStub().PutState(certificateId, certificateJSON)
+	return putCertificateState(ctx, certificate.CertificateID, certificateJSON)
 }
 
 // DeleteCertificate deletes a certificate from the world state
@@ -627,18 +746,122 @@ func (s *CertificateContract) DeleteCertificate(ctx contractapi.TransactionConte
 		}
 	}
 
-	// Delete certificate
-	return ctx.GetStub().DelState(certificateId)
+	// Delete freelancer and client relationships
+	err = delCertificatePartyIndexes(ctx, certificate)
+	if err != nil {
+		return err
+	}
+
+	// Let the milestone be certified again
+	err = delMilestoneCertificateID(ctx, certificate)
+	if err != nil {
+		return err
+	}
+
+	// Delete certificate, wherever it is stored
+	_, key, err := getCertificateState(ctx, certificateId)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// certificateKeyPrefix namespaces certificate records so they can be listed with an exact range.
+// Before schema version 3 certificates were stored under their bare ID.
+const certificateKeyPrefix = "cert:"
+
+// certificateKeyRangeEnd is the exclusive end of the certificate key range; ';' follows ':'
+const certificateKeyRangeEnd = "cert;"
+
+// certificateKey returns the state key of a certificate
+func certificateKey(certificateId string) string {
+	return certificateKeyPrefix + certificateId
+}
+
+// getCertificateState reads a certificate record and the key it is stored under, falling back
+// to the bare ID used before schema version 3. It returns nil if the certificate does not exist.
+func getCertificateState(ctx contractapi.TransactionContextInterface, certificateId string) ([]byte, string, error) {
+	key := certificateKey(certificateId)
+	certificateJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read certificate: %v", err)
+	}
+	if certificateJSON != nil {
+		return certificateJSON, key, nil
+	}
+
+	certificateJSON, err = ctx.GetStub().GetState(certificateId)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read certificate: %v", err)
+	}
+	if certificateJSON == nil {
+		return nil, key, nil
+	}
+
+	// The bare ID may belong to some other record
+	var legacy struct {
+		CertificateID string `json:"certificateId"`
+	}
+	if json.Unmarshal(certificateJSON, &legacy) != nil || legacy.CertificateID != certificateId {
+		return nil, key, nil
+	}
+
+	return certificateJSON, certificateId, nil
+}
+
+// putCertificateState stores a certificate under its cert: key and removes the copy under its bare ID, if any
+func putCertificateState(ctx contractapi.TransactionContextInterface, certificateId string, certificateJSON []byte) error {
+	_, key, err := getCertificateState(ctx, certificateId)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(certificateKey(certificateId), certificateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put certificate to state: %v", err)
+	}
+
+	if key != certificateKey(certificateId) {
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete legacy certificate key: %v", err)
+		}
+	}
+
+	return nil
 }
 
 func main() {
-	certificateContract, err := contractapi.NewChaincode(&CertificateContract{})
+	reputationContract := &ReputationContract{}
+	reputationContract.Name = "reputation"
+
+	certificateContract, err := contractapi.NewChaincode(&CertificateContract{}, reputationContract)
 	if err != nil {
 		fmt.Printf("Error creating certificate-registry chaincode: %v", err)
 		return
 	}
 
-	if err := certificateContract.Start(); err != nil {
+	if err := shim.Start(&positionalChaincode{certificateContract}); err != nil {
 		fmt.Printf("Error starting certificate-registry chaincode: %v", err)
 	}
 }
+
+// hasNoGroupHashArgument reports whether RegisterProject was invoked in a positional form
+// without an ipfsGroupHash argument. Only the web registry's form lacks it, so this is
+// always false in a build without web compatibility; see web_compat.go.
+func hasNoGroupHashArgument(ctx contractapi.TransactionContextInterface) bool {
+	if !features.webCompat {
+		return false
+	}
+
+	form := positionalForm(ctx)
+	if form == nil {
+		return false
+	}
+	for _, field := range form {
+		if field == "ipfsGroupHash" {
+			return false
+		}
+	}
+	return true
+}
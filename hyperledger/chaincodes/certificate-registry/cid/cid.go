@@ -0,0 +1,220 @@
+// Package cid parses IPFS content identifiers well enough to validate them and
+// to compare the content they address. It supports CIDv0 and CIDv1 in the
+// base32, base58btc and base16 multibase encodings.
+package cid
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Multicodec and multihash codes used by IPFS
+const (
+	CodecRaw     = 0x55
+	CodecDagPB   = 0x70
+	CodecDagCBOR = 0x71
+	CodecDagJSON = 0x0129
+
+	HashSHA2256 = 0x12
+)
+
+// codecNames names the content codecs IPFS clients produce
+var codecNames = map[uint64]string{
+	CodecRaw:     "raw",
+	CodecDagPB:   "dag-pb",
+	CodecDagCBOR: "dag-cbor",
+	CodecDagJSON: "dag-json",
+}
+
+// hashLengths holds the digest length of the hash functions that are checked
+var hashLengths = map[uint64]int{
+	HashSHA2256: 32,
+}
+
+// CID is a parsed content identifier
+type CID struct {
+	Version   int
+	Codec     uint64
+	HashCode  uint64
+	Digest    []byte
+	multihash []byte
+}
+
+// CodecName returns the name of the content codec, or its code in hex if it is not known
+func (c *CID) CodecName() string {
+	if name, ok := codecNames[c.Codec]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", c.Codec)
+}
+
+// FileSHA256 returns the SHA-256 digest of the file the CID addresses, if the CID
+// carries it. That is only the case for raw-codec CIDs hashed with sha2-256 (for
+// example `ipfs add --cid-version 1 --raw-leaves` of a file in a single block);
+// dag-pb CIDs hash the UnixFS node wrapping the file instead.
+func (c *CID) FileSHA256() ([]byte, bool) {
+	if c.Codec != CodecRaw || c.HashCode != HashSHA2256 {
+		return nil, false
+	}
+	return c.Digest, true
+}
+
+// SameContent reports whether two CIDs address the same content, regardless of
+// their version and multibase encoding
+func (c *CID) SameContent(other *CID) bool {
+	return c.Codec == other.Codec && bytes.Equal(c.multihash, other.multihash)
+}
+
+// Parse validates a CID string and decodes it
+func Parse(s string) (*CID, error) {
+	if s == "" {
+		return nil, fmt.Errorf("CID is empty")
+	}
+
+	// CIDv0 is a bare base58btc sha2-256 multihash, always 46 characters starting with Qm
+	if len(s) == 46 && strings.HasPrefix(s, "Qm") {
+		data, err := decodeBase58(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDv0 %s: %v", s, err)
+		}
+		c := &CID{Version: 0, Codec: CodecDagPB}
+		err = c.readMultihash(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDv0 %s: %v", s, err)
+		}
+		if c.HashCode != HashSHA2256 {
+			return nil, fmt.Errorf("invalid CIDv0 %s: multihash is not sha2-256", s)
+		}
+		return c, nil
+	}
+
+	if strings.HasPrefix(s, "Qm") {
+		return nil, fmt.Errorf("invalid CIDv0 %s: must be 46 characters", s)
+	}
+
+	data, err := decodeMultibase(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID %s: %v", s, err)
+	}
+
+	version, n := readUvarint(data)
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid CID %s: bad version", s)
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("invalid CID %s: unsupported version %d", s, version)
+	}
+	data = data[n:]
+
+	codec, n := readUvarint(data)
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid CID %s: bad codec", s)
+	}
+
+	c := &CID{Version: 1, Codec: codec}
+	err = c.readMultihash(data[n:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID %s: %v", s, err)
+	}
+
+	return c, nil
+}
+
+// readMultihash decodes a multihash that must make up all of data
+func (c *CID) readMultihash(data []byte) error {
+	code, n := readUvarint(data)
+	if n <= 0 {
+		return fmt.Errorf("bad multihash code")
+	}
+	length, m := readUvarint(data[n:])
+	if m <= 0 {
+		return fmt.Errorf("bad multihash length")
+	}
+
+	digest := data[n+m:]
+	if uint64(len(digest)) != length {
+		return fmt.Errorf("multihash length %d does not match digest of %d bytes", length, len(digest))
+	}
+	if expected, ok := hashLengths[code]; ok && len(digest) != expected {
+		return fmt.Errorf("digest of hash function 0x%x must be %d bytes", code, expected)
+	}
+
+	c.HashCode = code
+	c.Digest = digest
+	c.multihash = data
+	return nil
+}
+
+// readUvarint decodes an unsigned varint as used by multiformats, which limits it to 9 bytes.
+// It returns the number of bytes read, or 0 if data does not start with a valid varint.
+func readUvarint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 9; i++ {
+		b := data[i]
+		value |= uint64(b&0x7f) << (7 * uint(i))
+		if b&0x80 == 0 {
+			// Multiformats varints must be minimally encoded
+			if b == 0 && i > 0 {
+				return 0, 0
+			}
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// decodeMultibase decodes a multibase string in one of the encodings IPFS uses for CIDv1
+func decodeMultibase(s string) ([]byte, error) {
+	if len(s) < 2 {
+		return nil, fmt.Errorf("too short")
+	}
+
+	prefix, body := s[0], s[1:]
+	switch prefix {
+	case 'b', 'B':
+		return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(body))
+	case 'z':
+		return decodeBase58(body)
+	case 'f', 'F':
+		return hex.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("unsupported multibase prefix %q", prefix)
+	}
+}
+
+// base58Alphabet is the Bitcoin alphabet used by base58btc
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// decodeBase58 decodes base58btc
+func decodeBase58(s string) ([]byte, error) {
+	var result []byte
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(base58Alphabet, s[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", s[i])
+		}
+
+		// result = result*58 + digit, on big-endian bytes
+		carry := digit
+		for j := len(result) - 1; j >= 0; j-- {
+			carry += int(result[j]) * 58
+			result[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			result = append([]byte{byte(carry)}, result...)
+			carry >>= 8
+		}
+	}
+
+	// Leading '1's encode leading zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), result...), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/cid"
+)
+
+// VerifyDocument checks a certificate document against the SHA-256 digest recorded at
+// registration, for verifiers who have the file but no IPFS node. sha256Hex is the hex
+// digest of the file, as printed by `sha256sum`.
+func (s *CertificateContract) VerifyDocument(ctx contractapi.TransactionContextInterface, certificateId string, sha256Hex string) (bool, error) {
+	digest, err := normalizeSHA256(sha256Hex)
+	if err != nil {
+		return false, err
+	}
+	if digest == "" {
+		return false, fmt.Errorf("sha256 is required")
+	}
+
+	certificate, err := s.GetCertificate(ctx, certificateId)
+	if err != nil {
+		return false, err
+	}
+
+	if certificate.ContentSHA256 == "" {
+		return false, fmt.Errorf("certificate %s was registered without a content digest", certificateId)
+	}
+
+	return certificate.ContentSHA256 == digest, nil
+}
+
+// setContentInfo validates the CID of a certificate and records its version, codec and the
+// document digest. When the CID itself is the SHA-256 of the file (raw codec, sha2-256),
+// the given digest must match it and is derived from it if left empty.
+func setContentInfo(certificate *Certificate, contentSha256 string) error {
+	parsed, err := cid.Parse(certificate.IPFSHash)
+	if err != nil {
+		return fmt.Errorf("ipfsHash is not a valid CID: %v", err)
+	}
+
+	digest, err := normalizeSHA256(contentSha256)
+	if err != nil {
+		return err
+	}
+
+	if fileDigest, ok := parsed.FileSHA256(); ok {
+		cidDigest := hex.EncodeToString(fileDigest)
+		if digest != "" && digest != cidDigest {
+			return fmt.Errorf("contentSha256 %s does not match the digest in CID %s", digest, certificate.IPFSHash)
+		}
+		digest = cidDigest
+	}
+
+	certificate.ContentSHA256 = digest
+	certificate.CIDVersion = parsed.Version
+	certificate.CIDCodec = parsed.CodecName()
+	return nil
+}
+
+// normalizeSHA256 lowercases a hex SHA-256 digest and checks its length; an empty digest stays empty
+func normalizeSHA256(sha256Hex string) (string, error) {
+	digest := strings.ToLower(strings.TrimSpace(sha256Hex))
+	if digest == "" {
+		return "", nil
+	}
+
+	decoded, err := hex.DecodeString(digest)
+	if err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("SHA-256 digest %q must be 64 hex characters", sha256Hex)
+	}
+
+	return digest, nil
+}
+
+// sameCID reports whether two IPFS hashes are equal, or are CIDs of the same content
+func sameCID(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	parsedA, err := cid.Parse(a)
+	if err != nil {
+		return false
+	}
+	parsedB, err := cid.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return parsedA.SameContent(parsedB)
+}
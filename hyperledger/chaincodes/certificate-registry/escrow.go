@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// escrowChaincodeName is the name the escrow chaincode is deployed under on the same channel
+const escrowChaincodeName = "escrow"
+
+// escrowContract mirrors the fields of the escrow chaincode's contract record that certificates depend on
+type escrowContract struct {
+	ContractID        string            `json:"contractId"`
+	ProjectID         string            `json:"projectId"`
+	ClientAddress     string            `json:"clientAddress"`
+	FreelancerAddress string            `json:"freelancerAddress"`
+	Status            string            `json:"status"`
+	Milestones        []escrowMilestone `json:"milestones"`
+}
+
+// escrowMilestone mirrors a milestone of the escrow chaincode
+type escrowMilestone struct {
+	MilestoneID string `json:"milestoneId"`
+	Amount      string `json:"amount"`
+	Status      string `json:"status"`
+	ReleaseTxID string `json:"releaseTxId"`
+}
+
+// getEscrowContract reads a contract from the escrow chaincode with a read-only chaincode call
+func getEscrowContract(ctx contractapi.TransactionContextInterface, contractId string) (*escrowContract, error) {
+	args := [][]byte{[]byte("GetContract"), []byte(contractId)}
+	response := ctx.GetStub().InvokeChaincode(escrowChaincodeName, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("failed to query escrow contract %s: %s", contractId, response.Message)
+	}
+
+	var contract escrowContract
+	err := json.Unmarshal(response.Payload, &contract)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal escrow contract: %v", err)
+	}
+
+	return &contract, nil
+}
+
+// getEscrowContractsByProject reads the contracts of a project from the escrow chaincode
+func getEscrowContractsByProject(ctx contractapi.TransactionContextInterface, projectId string) ([]escrowContract, error) {
+	args := [][]byte{[]byte("GetContractsByProject"), []byte(projectId)}
+	response := ctx.GetStub().InvokeChaincode(escrowChaincodeName, args, "")
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("failed to query escrow contracts of project %s: %s", projectId, response.Message)
+	}
+
+	var contracts []escrowContract
+	err := json.Unmarshal(response.Payload, &contracts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal escrow contracts: %v", err)
+	}
+
+	return contracts, nil
+}
+
+// findEscrowContractID looks up the escrow contract a certificate without a contract ID was
+// issued for: the contract holding its milestone, or for a CONTRACT certificate the contract
+// with the same freelancer. It returns an empty ID unless exactly one contract matches.
+func findEscrowContractID(ctx contractapi.TransactionContextInterface, certificate *Certificate) (string, error) {
+	contracts, err := getEscrowContractsByProject(ctx, certificate.ProjectID)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, contract := range contracts {
+		if certificate.MilestoneID == "" {
+			if contract.FreelancerAddress == certificate.FreelancerID {
+				matches = append(matches, contract.ContractID)
+			}
+			continue
+		}
+
+		for _, milestone := range contract.Milestones {
+			if milestone.MilestoneID == certificate.MilestoneID {
+				matches = append(matches, contract.ContractID)
+				break
+			}
+		}
+	}
+
+	if len(matches) != 1 {
+		return "", nil
+	}
+
+	return matches[0], nil
+}
+
+// milestoneCertificateIndex maps an escrow contract and milestone to the one certificate issued for it
+const milestoneCertificateIndex = "contract~milestone"
+
+// verifyMilestoneRelease confirms with the escrow chaincode that a milestone was released
+// to the given freelancer by the given client for the given amount and returns the ID of the
+// release transaction, which is empty for milestones released before escrow recorded it
+func verifyMilestoneRelease(ctx contractapi.TransactionContextInterface, projectId string, contractId string, milestoneId string, freelancerId string, clientId string, amount string) (string, error) {
+	contract, err := getEscrowContract(ctx, contractId)
+	if err != nil {
+		return "", err
+	}
+
+	if contract.ProjectID != projectId {
+		return "", fmt.Errorf("escrow contract %s belongs to project %s, not %s", contractId, contract.ProjectID, projectId)
+	}
+	if contract.FreelancerAddress != freelancerId {
+		return "", fmt.Errorf("freelancer %s is not the freelancer of escrow contract %s", freelancerId, contractId)
+	}
+	if contract.ClientAddress != clientId {
+		return "", fmt.Errorf("client %s is not the client of escrow contract %s", clientId, contractId)
+	}
+
+	for _, milestone := range contract.Milestones {
+		if milestone.MilestoneID != milestoneId {
+			continue
+		}
+
+		if milestone.Status != "RELEASED" {
+			return "", fmt.Errorf("milestone %s of escrow contract %s is %s, not RELEASED", milestoneId, contractId, milestone.Status)
+		}
+
+		releasedAmount, _, err := parseDecimal(milestone.Amount)
+		if err != nil {
+			return "", fmt.Errorf("escrow milestone %s has an invalid amount: %v", milestoneId, err)
+		}
+		certificateAmount, _, err := parseDecimal(amount)
+		if err != nil {
+			return "", fmt.Errorf("certificate amount is invalid: %v", err)
+		}
+		if releasedAmount.Cmp(certificateAmount) != 0 {
+			return "", fmt.Errorf("certificate amount %s does not match released amount %s for milestone %s", amount, milestone.Amount, milestoneId)
+		}
+
+		return milestone.ReleaseTxID, nil
+	}
+
+	return "", fmt.Errorf("milestone %s not found in escrow contract %s", milestoneId, contractId)
+}
+
+// getMilestoneCertificateID returns the ID of the certificate issued for a milestone of an
+// escrow contract, or an empty string if there is none
+func getMilestoneCertificateID(ctx contractapi.TransactionContextInterface, contractId string, milestoneId string) (string, error) {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(milestoneCertificateIndex, []string{contractId, milestoneId})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	certificateId, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s index: %v", milestoneCertificateIndex, err)
+	}
+
+	return string(certificateId), nil
+}
+
+// putMilestoneCertificateID records the certificate issued for a milestone of an escrow contract
+func putMilestoneCertificateID(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(milestoneCertificateIndex, []string{certificate.ContractID, certificate.MilestoneID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte(certificate.CertificateID))
+	if err != nil {
+		return fmt.Errorf("failed to put %s index: %v", milestoneCertificateIndex, err)
+	}
+
+	return nil
+}
+
+// delMilestoneCertificateID removes the milestone entry of a certificate, if it points to it
+func delMilestoneCertificateID(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	if certificate.ContractID == "" || certificate.MilestoneID == "" {
+		return nil
+	}
+
+	certificateId, err := getMilestoneCertificateID(ctx, certificate.ContractID, certificate.MilestoneID)
+	if err != nil {
+		return err
+	}
+	if certificateId != certificate.CertificateID {
+		return nil
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(milestoneCertificateIndex, []string{certificate.ContractID, certificate.MilestoneID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(indexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s index: %v", milestoneCertificateIndex, err)
+	}
+
+	return nil
+}
+
+// verifyContractParties confirms with the escrow chaincode that a contract belongs to the
+// given project and is between the given client and freelancer
+func verifyContractParties(ctx contractapi.TransactionContextInterface, projectId string, contractId string, freelancerId string, clientId string) error {
+	contract, err := getEscrowContract(ctx, contractId)
+	if err != nil {
+		return err
+	}
+
+	if contract.ProjectID != projectId {
+		return fmt.Errorf("escrow contract %s belongs to project %s, not %s", contractId, contract.ProjectID, projectId)
+	}
+	if contract.FreelancerAddress == "" || contract.FreelancerAddress != freelancerId {
+		return fmt.Errorf("freelancer %s is not the freelancer of escrow contract %s", freelancerId, contractId)
+	}
+	if contract.ClientAddress != clientId {
+		return fmt.Errorf("client %s is not the client of escrow contract %s", clientId, contractId)
+	}
+
+	return nil
+}
+
+// isEscrowReleaseProposal reports whether the current transaction is the escrow chaincode's
+// ReleaseMilestone for the given milestone. Escrow issues milestone certificates from inside that
+// transaction, where the release is not yet committed and so cannot be confirmed by querying escrow.
+func isEscrowReleaseProposal(ctx contractapi.TransactionContextInterface, contractId string, milestoneId string) (bool, error) {
+	return isEscrowProposal(ctx, "ReleaseMilestone", contractId, milestoneId)
+}
+
+// isEscrowLockProposal reports whether the current transaction is the escrow chaincode's
+// LockFunds for the given contract, from which escrow issues the contract certificate
+func isEscrowLockProposal(ctx contractapi.TransactionContextInterface, contractId string) (bool, error) {
+	return isEscrowProposal(ctx, "LockFunds", contractId)
+}
+
+// isEscrowProposal reports whether the current transaction is a call of the given escrow
+// function whose leading arguments are args
+func isEscrowProposal(ctx contractapi.TransactionContextInterface, function string, args ...string) (bool, error) {
+	chaincodeName, proposalArgs, err := getProposalInvocation(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if chaincodeName != escrowChaincodeName || len(proposalArgs) < len(args)+1 {
+		return false, nil
+	}
+
+	proposalFunction := proposalArgs[0]
+	if i := strings.LastIndex(proposalFunction, ":"); i >= 0 {
+		proposalFunction = proposalFunction[i+1:]
+	}
+	if proposalFunction != function {
+		return false, nil
+	}
+
+	for i, arg := range args {
+		if proposalArgs[i+1] != arg {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getProposalInvocation returns the chaincode name and arguments of the signed proposal.
+// In a chaincode-to-chaincode call this is the chaincode the client invoked, not this one.
+func getProposalInvocation(ctx contractapi.TransactionContextInterface) (string, []string, error) {
+	signedProposal, err := ctx.GetStub().GetSignedProposal()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get signed proposal: %v", err)
+	}
+	if signedProposal == nil {
+		return "", nil, nil
+	}
+
+	proposal := &peer.Proposal{}
+	err = proto.Unmarshal(signedProposal.ProposalBytes, proposal)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal proposal: %v", err)
+	}
+
+	payload := &peer.ChaincodeProposalPayload{}
+	err = proto.Unmarshal(proposal.Payload, payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal proposal payload: %v", err)
+	}
+
+	invocation := &peer.ChaincodeInvocationSpec{}
+	err = proto.Unmarshal(payload.Input, invocation)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal invocation spec: %v", err)
+	}
+
+	spec := invocation.GetChaincodeSpec()
+	var args []string
+	for _, arg := range spec.GetInput().GetArgs() {
+		args = append(args, string(arg))
+	}
+
+	return spec.GetChaincodeId().GetName(), args, nil
+}
@@ -0,0 +1,9 @@
+package main
+
+// features holds the optional parts of the chaincode, selected with build tags.
+// The default build includes all of them; see web_compat.go.
+var features struct {
+	// webCompat accepts the transactions and argument forms of the chaincode that
+	// was deployed from web/chaincode/certificate-registry under the same name
+	webCompat bool
+}
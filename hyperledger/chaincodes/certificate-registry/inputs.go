@@ -0,0 +1,62 @@
+package main
+
+// Transaction inputs. contractapi publishes these structs as JSON schemas in the
+// contract metadata and validates every incoming argument against them, so new
+// optional fields can be added without breaking existing clients.
+
+// ProjectInput is the argument of RegisterProject
+type ProjectInput struct {
+	ProjectID      string   `json:"projectId"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description,omitempty" metadata:",optional"`
+	Category       string   `json:"category,omitempty" metadata:",optional"`
+	ClientID       string   `json:"clientId"`
+	TotalBudget    string   `json:"totalBudget,omitempty" metadata:",optional"`
+	Deadline       string   `json:"deadline,omitempty" metadata:",optional"`
+	SkillsRequired []string `json:"skillsRequired,omitempty" metadata:",optional"`
+	IPFSHash       string   `json:"ipfsHash"`
+	IPFSGroupHash  string   `json:"ipfsGroupHash,omitempty" metadata:",optional"` // IPFS hash of the group created for the project; required except in the web registry's positional form
+}
+
+// ContractCertificateInput is the argument of RegisterContractCertificate
+type ContractCertificateInput struct {
+	CertificateID   string `json:"certificateId"`
+	ProjectID       string `json:"projectId"`
+	ContractID      string `json:"contractId"`
+	IPFSHash        string `json:"ipfsHash"`
+	TransactionHash string `json:"transactionHash,omitempty" metadata:",optional"`
+	FreelancerID    string `json:"freelancerId"`
+	ClientID        string `json:"clientId"`
+	Amount          string `json:"amount"`
+	ContentSHA256   string `json:"contentSha256,omitempty" metadata:",optional"` // hex SHA-256 of the certificate document
+	ValidFrom       string `json:"validFrom,omitempty" metadata:",optional"`     // RFC 3339; defaults to the registration time
+	ValidUntil      string `json:"validUntil,omitempty" metadata:",optional"`    // RFC 3339; empty means the certificate does not lapse
+}
+
+// MilestoneCertificateInput is the argument of RegisterMilestoneCertificate
+type MilestoneCertificateInput struct {
+	CertificateID   string `json:"certificateId"`
+	ProjectID       string `json:"projectId"`
+	ContractID      string `json:"contractId"`
+	MilestoneID     string `json:"milestoneId"`
+	IPFSHash        string `json:"ipfsHash"`
+	TransactionHash string `json:"transactionHash,omitempty" metadata:",optional"` // filled from escrow when empty
+	FreelancerID    string `json:"freelancerId"`
+	ClientID        string `json:"clientId"`
+	Amount          string `json:"amount"`
+	ContentSHA256   string `json:"contentSha256,omitempty" metadata:",optional"` // hex SHA-256 of the certificate document
+	ValidFrom       string `json:"validFrom,omitempty" metadata:",optional"`     // RFC 3339; defaults to the registration time
+	ValidUntil      string `json:"validUntil,omitempty" metadata:",optional"`    // RFC 3339; empty means the certificate does not lapse
+}
+
+// RenewalInput is the argument of RenewCertificate
+type RenewalInput struct {
+	CertificateID    string `json:"certificateId"`    // certificate being renewed
+	NewCertificateID string `json:"newCertificateId"` // ID of the successor
+	IPFSHash         string `json:"ipfsHash"`         // document of the successor
+	ContentSHA256    string `json:"contentSha256,omitempty" metadata:",optional"`
+	TransactionHash  string `json:"transactionHash,omitempty" metadata:",optional"`
+	Amount           string `json:"amount,omitempty" metadata:",optional"`     // defaults to the renewed certificate's amount
+	ValidFrom        string `json:"validFrom,omitempty" metadata:",optional"`  // defaults to the end of the renewed certificate, or now
+	ValidUntil       string `json:"validUntil,omitempty" metadata:",optional"` // empty means the successor does not lapse
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"chaincode-common/access"
+	"chaincode-common/schema"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
+)
+
+// schemaVersionKey holds the version of the record layout the world state has been migrated to.
+// Version 1 is the layout of the web registry; version 2 adds certificate types, contract IDs,
+// IPFS groups for every project, and the composite indexes used by the queries; version 3
+// stores certificates under the cert: key prefix; version 4 records the CID version, codec
+// and, where the CID carries it, the content digest of certificates; version 5 indexes
+// milestone certificates by escrow contract and milestone; version 6 stores project
+// budgets in cents.
+const schemaVersionKey = "schema:version"
+
+// maxMigrationBatchSize bounds the number of records one MigrateState transaction rewrites
+const maxMigrationBatchSize = 500
+
+// schemaRegistry tracks the schema version of the registry's world state
+var schemaRegistry = newSchemaRegistry()
+
+// newSchemaRegistry lists the upgrade steps of the registry's record layout. Each step scans
+// the ledger once and applies only its own change; MigrateState applies all of them to each
+// record in one pass.
+func newSchemaRegistry() *schema.Registry {
+	registry := schema.NewRegistry(schemaVersionKey, 6,
+		schema.Step{
+			Version:     2,
+			Description: "backfill certificate types, contract IDs, IPFS groups and indexes",
+			Apply:       recordMigration{project: backfillProject, certificate: backfillCertificate}.apply,
+		},
+		schema.Step{
+			Version:     3,
+			Description: "move certificates under the cert: key prefix",
+			Apply:       recordMigration{certificate: moveCertificate}.apply,
+		},
+		schema.Step{
+			Version:     4,
+			Description: "record CID version, codec and content digest of certificates",
+			Apply:       recordMigration{certificate: recordContentInfo}.apply,
+		},
+		schema.Step{
+			Version:     5,
+			Description: "index milestone certificates by escrow contract and milestone",
+			Apply:       recordMigration{certificate: indexMilestoneCertificate}.apply,
+		},
+		schema.Step{
+			Version:     6,
+			Description: "store project budgets in cents for numeric budget queries",
+			Apply:       recordMigration{project: storeBudgetCents}.apply,
+		},
+	)
+	registry.HasLegacyState = hasRecords
+	return registry
+}
+
+// fullMigration brings a record of any earlier version to the current layout
+var fullMigration = recordMigration{project: backfillProject, certificate: migrateCertificate}
+
+// recordMigration rewrites the project and certificate records of the world state. Each
+// function migrates one record and reports whether it changed anything; a nil function
+// leaves records of that kind alone.
+type recordMigration struct {
+	project     func(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error)
+	certificate func(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error)
+}
+
+// apply migrates every record in one transaction
+func (m recordMigration) apply(ctx contractapi.TransactionContextInterface) error {
+	_, err := migrateRecords(ctx, m, "", 0)
+	return err
+}
+
+// MigrationResult reports the progress of one MigrateState batch
+type MigrationResult struct {
+	Processed     int    `json:"processed"`     // records read in this batch
+	Updated       int    `json:"updated"`       // records rewritten, or given a group or missing indexes
+	Bookmark      string `json:"bookmark"`      // pass to the next MigrateState call; empty when done
+	Done          bool   `json:"done"`          // true once every record has been migrated
+	SchemaVersion int    `json:"schemaVersion"` // schema version recorded on the ledger
+}
+
+// compositeIndex is an index entry a record is expected to have
+type compositeIndex struct {
+	name       string
+	attributes []string
+}
+
+// InitLedger records the schema version on a new ledger. It does nothing on a ledger
+// that already holds records; upgrade those with UpgradeSchema or MigrateState.
+func (s *CertificateContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	_, err := schemaRegistry.Init(ctx, nil)
+	return err
+}
+
+// UpgradeSchema applies the next schema upgrade step; call it until done is true.
+// Each step scans the whole ledger in one transaction. On large ledgers run
+// MigrateState in batches instead, which records the same version when it finishes.
+func (s *CertificateContract) UpgradeSchema(ctx contractapi.TransactionContextInterface) (*schema.UpgradeResult, error) {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaRegistry.Upgrade(ctx)
+}
+
+// MigrateState upgrades records written by earlier versions of the chaincode, batchSize
+// records at a time. Call it with an empty bookmark first, then with the returned bookmark
+// until done is true; the schema version is recorded after the last batch. Batches can be
+// rerun safely, records that are already up to date are left as they are.
+func (s *CertificateContract) MigrateState(ctx contractapi.TransactionContextInterface, batchSize int, bookmark string) (*MigrationResult, error) {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if batchSize <= 0 || batchSize > maxMigrationBatchSize {
+		return nil, fmt.Errorf("batchSize must be between 1 and %d", maxMigrationBatchSize)
+	}
+
+	result, err := migrateRecords(ctx, fullMigration, bookmark, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := schemaRegistry.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Done && version < schemaRegistry.Current() {
+		err = schemaRegistry.SetVersion(ctx, schemaRegistry.Current())
+		if err != nil {
+			return nil, err
+		}
+		version = schemaRegistry.Current()
+	}
+	result.SchemaVersion = version
+
+	return result, nil
+}
+
+// GetSchemaVersion returns the schema version of the world state: 0 for a ledger that was
+// never initialized, 1 for records not yet migrated
+func (s *CertificateContract) GetSchemaVersion(ctx contractapi.TransactionContextInterface) (int, error) {
+	return schemaRegistry.Version(ctx)
+}
+
+// migrateRecords applies a migration to up to batchSize records starting at bookmark, or to all
+// of them if batchSize is 0
+func migrateRecords(ctx contractapi.TransactionContextInterface, migration recordMigration, bookmark string, batchSize int) (*MigrationResult, error) {
+	// Paginated queries are not allowed in update transactions, so the range is cut by hand.
+	// Composite keys are not returned by a range query, which leaves only primary records.
+	resultsIterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state by range: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	result := &MigrationResult{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next record: %v", err)
+		}
+
+		if batchSize > 0 && result.Processed == batchSize {
+			result.Bookmark = queryResponse.Key
+			break
+		}
+		result.Processed++
+
+		migrate := migration.certificate
+		switch {
+		case queryResponse.Key == schemaVersionKey, strings.HasPrefix(queryResponse.Key, "group:"):
+			continue
+		case strings.HasPrefix(queryResponse.Key, "project:"):
+			migrate = migration.project
+		}
+		if migrate == nil {
+			continue
+		}
+
+		updated, err := migrate(ctx, queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate %s: %v", queryResponse.Key, err)
+		}
+		if updated {
+			result.Updated++
+		}
+	}
+
+	result.Done = result.Bookmark == ""
+	return result, nil
+}
+
+// hasRecords reports whether the world state holds any record besides the schema version
+func hasRecords(ctx contractapi.TransactionContextInterface) (bool, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return false, fmt.Errorf("failed to get state by range: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return false, fmt.Errorf("failed to get next record: %v", err)
+		}
+		if queryResponse.Key != schemaVersionKey {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// backfillProject gives a project the document type, IPFS group and search indexes of schema
+// version 2. Decoding also fills in the budget in cents, so a project it rewrites is already
+// in the current layout.
+func backfillProject(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	project, updated, err := model.DecodeProject(value)
+	if err != nil {
+		// Not a project record; leave it alone
+		return false, nil
+	}
+
+	// The web registry did not create groups, so the group hash is unknown
+	groupJSON, err := ctx.GetStub().GetState(project.IPFSGroupID)
+	if err != nil {
+		return false, fmt.Errorf("failed to read group: %v", err)
+	}
+	if groupJSON == nil {
+		txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+		if err != nil {
+			return false, fmt.Errorf("failed to get transaction timestamp: %v", err)
+		}
+
+		members := []string{}
+		if project.ClientID != "" {
+			members = append(members, project.ClientID)
+		}
+
+		group := IPFSGroup{
+			GroupID:   project.IPFSGroupID,
+			ProjectID: project.ProjectID,
+			Members:   members,
+			CreatedAt: txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
+			UpdatedAt: txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
+		}
+
+		groupJSON, err = json.Marshal(group)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal group: %v", err)
+		}
+		err = ctx.GetStub().PutState(project.IPFSGroupID, groupJSON)
+		if err != nil {
+			return false, fmt.Errorf("failed to put group to state: %v", err)
+		}
+		updated = true
+	}
+
+	if updated {
+		err = putProjectRecord(ctx, key, project)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	indexed, err := putMissingIndexes(ctx, projectIndexes(project))
+	if err != nil {
+		return false, err
+	}
+
+	return updated || indexed, nil
+}
+
+// storeBudgetCents rewrites a project stored before budgets were kept in cents
+func storeBudgetCents(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	project, updated, err := model.DecodeProject(value)
+	if err != nil || !updated {
+		return false, nil
+	}
+
+	return true, putProjectRecord(ctx, key, project)
+}
+
+// putProjectRecord writes a migrated project back under its key
+func putProjectRecord(ctx contractapi.TransactionContextInterface, key string, project *Project) error {
+	projectJSON, err := json.Marshal(project)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, projectJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put project to state: %v", err)
+	}
+
+	return nil
+}
+
+// backfillCertificate infers the type, contract and IPFS group of a certificate and writes its
+// indexes, leaving it under the key it is stored at
+func backfillCertificate(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	certificate, updated, err := model.DecodeCertificate(value)
+	if err != nil {
+		// Not a certificate record; leave it alone
+		return false, nil
+	}
+
+	filled, err := backfillCertificateRefs(ctx, certificate)
+	if err != nil {
+		return false, err
+	}
+	updated = updated || filled
+
+	if updated {
+		err = putCertificateRecord(ctx, key, certificate)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	indexed, err := putMissingIndexes(ctx, certificateIndexes(certificate))
+	if err != nil {
+		return false, err
+	}
+
+	return updated || indexed, nil
+}
+
+// moveCertificate moves a certificate stored under its bare ID to its cert: key
+func moveCertificate(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	certificate, _, err := model.DecodeCertificate(value)
+	if err != nil || key == certificateKey(certificate.CertificateID) {
+		return false, nil
+	}
+
+	err = ctx.GetStub().PutState(certificateKey(certificate.CertificateID), value)
+	if err != nil {
+		return false, fmt.Errorf("failed to put certificate to state: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete legacy certificate key: %v", err)
+	}
+
+	return true, nil
+}
+
+// recordContentInfo records the CID version, codec and content digest of a certificate.
+// Older certificates were not checked for a valid CID; those that fail are left without them.
+func recordContentInfo(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	certificate, _, err := model.DecodeCertificate(value)
+	if err != nil || certificate.CIDCodec != "" {
+		return false, nil
+	}
+
+	if setContentInfo(certificate, certificate.ContentSHA256) != nil {
+		return false, nil
+	}
+
+	return true, putCertificateRecord(ctx, key, certificate)
+}
+
+// indexMilestoneCertificate records a milestone certificate under its escrow contract and milestone
+func indexMilestoneCertificate(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	certificate, _, err := model.DecodeCertificate(value)
+	if err != nil {
+		return false, nil
+	}
+
+	return putMissingMilestoneCertificateID(ctx, certificate)
+}
+
+// migrateCertificate applies every certificate step at once: it infers the type, contract and
+// IPFS group of a certificate, records its CID info, moves it under its cert: key and writes
+// its indexes
+func migrateCertificate(ctx contractapi.TransactionContextInterface, key string, value []byte) (bool, error) {
+	certificate, updated, err := model.DecodeCertificate(value)
+	if err != nil {
+		// Not a certificate record; leave it alone
+		return false, nil
+	}
+
+	// Certificates were stored under their bare ID before schema version 3
+	moved := key != certificateKey(certificate.CertificateID)
+
+	if certificate.CIDCodec == "" && setContentInfo(certificate, certificate.ContentSHA256) == nil {
+		updated = true
+	}
+
+	filled, err := backfillCertificateRefs(ctx, certificate)
+	if err != nil {
+		return false, err
+	}
+	updated = updated || filled
+
+	if updated || moved {
+		err = putCertificateRecord(ctx, certificateKey(certificate.CertificateID), certificate)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if moved {
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete legacy certificate key: %v", err)
+		}
+	}
+
+	indexed, err := putMissingIndexes(ctx, certificateIndexes(certificate))
+	if err != nil {
+		return false, err
+	}
+
+	milestoneIndexed, err := putMissingMilestoneCertificateID(ctx, certificate)
+	if err != nil {
+		return false, err
+	}
+
+	return updated || moved || indexed || milestoneIndexed, nil
+}
+
+// backfillCertificateRefs fills in the IPFS group of a certificate whose project is registered
+// and the escrow contract it was issued for, where they are missing
+func backfillCertificateRefs(ctx contractapi.TransactionContextInterface, certificate *Certificate) (bool, error) {
+	updated := false
+	if certificate.IPFSGroupID == "" {
+		projectJSON, err := ctx.GetStub().GetState("project:" + certificate.ProjectID)
+		if err != nil {
+			return false, fmt.Errorf("failed to read project: %v", err)
+		}
+		if projectJSON != nil {
+			certificate.IPFSGroupID = model.GroupID(certificate.ProjectID)
+			updated = true
+		}
+	}
+
+	if certificate.ContractID == "" {
+		contractId, err := findEscrowContractID(ctx, certificate)
+		if err != nil {
+			return false, err
+		}
+		if contractId != "" {
+			certificate.ContractID = contractId
+			updated = true
+		}
+	}
+
+	return updated, nil
+}
+
+// putMissingMilestoneCertificateID indexes a milestone certificate under its escrow contract and
+// milestone unless the milestone already has a certificate. The first certificate found for a
+// milestone keeps it; later ones are left unindexed.
+func putMissingMilestoneCertificateID(ctx contractapi.TransactionContextInterface, certificate *Certificate) (bool, error) {
+	if certificate.CertificateType != model.CertificateTypeMilestone || certificate.ContractID == "" || certificate.MilestoneID == "" {
+		return false, nil
+	}
+
+	certificateId, err := getMilestoneCertificateID(ctx, certificate.ContractID, certificate.MilestoneID)
+	if err != nil {
+		return false, err
+	}
+	if certificateId != "" {
+		return false, nil
+	}
+
+	err = putMilestoneCertificateID(ctx, certificate)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// putCertificateRecord writes a migrated certificate under the given key
+func putCertificateRecord(ctx contractapi.TransactionContextInterface, key string, certificate *Certificate) error {
+	certificateJSON, err := json.Marshal(certificate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificate: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(key, certificateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put certificate to state: %v", err)
+	}
+
+	return nil
+}
+
+// projectIndexes lists the category~project and skill~project entries of a project
+func projectIndexes(project *Project) []compositeIndex {
+	var indexes []compositeIndex
+	if project.Category != "" {
+		indexes = append(indexes, compositeIndex{"category~project", []string{normalizeIndexValue(project.Category), project.ProjectID}})
+	}
+
+	for _, skill := range project.SkillsRequired {
+		if strings.TrimSpace(skill) == "" {
+			continue
+		}
+		indexes = append(indexes, compositeIndex{"skill~project", []string{normalizeIndexValue(skill), project.ProjectID}})
+	}
+
+	return indexes
+}
+
+// certificateIndexes lists the project, group, freelancer and client entries of a certificate
+func certificateIndexes(certificate *Certificate) []compositeIndex {
+	indexes := []compositeIndex{
+		{"project~certificate", []string{certificate.ProjectID, certificate.CertificateID}},
+	}
+	if certificate.IPFSGroupID != "" {
+		indexes = append(indexes, compositeIndex{"group~certificate", []string{certificate.IPFSGroupID, certificate.CertificateID}})
+	}
+	if certificate.FreelancerID != "" {
+		indexes = append(indexes, compositeIndex{"freelancer~certificate", []string{certificate.FreelancerID, certificate.CertificateID}})
+	}
+	if certificate.ClientID != "" {
+		indexes = append(indexes, compositeIndex{"client~certificate", []string{certificate.ClientID, certificate.CertificateID}})
+	}
+
+	return indexes
+}
+
+// putMissingIndexes writes the index entries that do not exist yet and reports whether any did
+func putMissingIndexes(ctx contractapi.TransactionContextInterface, indexes []compositeIndex) (bool, error) {
+	written := false
+	for _, index := range indexes {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index.name, index.attributes)
+		if err != nil {
+			return false, fmt.Errorf("failed to create composite key: %v", err)
+		}
+
+		existing, err := ctx.GetStub().GetState(indexKey)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s index: %v", index.name, err)
+		}
+		if existing != nil {
+			continue
+		}
+
+		err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return false, fmt.Errorf("failed to put %s index: %v", index.name, err)
+		}
+		written = true
+	}
+
+	return written, nil
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DecodeCertificate unmarshals a certificate written by either chaincode version.
+// Fields the older version did not write are filled in where they can be derived:
+// the certificate type is MILESTONE when a milestone ID is present and CONTRACT
+// otherwise. The IPFS group cannot be derived from the certificate alone and is
+// left empty. upgraded reports whether any field was filled in.
+func DecodeCertificate(data []byte) (certificate *Certificate, upgraded bool, err error) {
+	certificate = &Certificate{}
+	err = json.Unmarshal(data, certificate)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal certificate: %v", err)
+	}
+
+	if certificate.CertificateID == "" {
+		return nil, false, fmt.Errorf("record is not a certificate: missing certificateId")
+	}
+
+	if certificate.CertificateType == "" {
+		certificate.CertificateType = InferCertificateType(certificate)
+		upgraded = true
+	}
+
+	return certificate, upgraded, nil
+}
+
+// NoBudgetCents is the BudgetCents of a project whose TotalBudget has no value in cents. It is
+// negative, so budget filters with a lower bound of zero or more never match such a project.
+const NoBudgetCents int64 = -1
+
+// DecodeProject unmarshals a project written by either chaincode version.
+// Projects from the older version get the project document type and the
+// group ID the registry would have created for them; the group record
+// itself may still have to be created. Projects stored without a budgetCents
+// field get it from TotalBudget. upgraded reports whether any field was filled in.
+func DecodeProject(data []byte) (project *Project, upgraded bool, err error) {
+	project = &Project{}
+	err = json.Unmarshal(data, project)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal project: %v", err)
+	}
+
+	if project.ProjectID == "" {
+		return nil, false, fmt.Errorf("record is not a project: missing projectId")
+	}
+
+	if project.DocType == "" {
+		project.DocType = ProjectDocType
+		upgraded = true
+	}
+
+	if project.IPFSGroupID == "" {
+		project.IPFSGroupID = GroupID(project.ProjectID)
+		upgraded = true
+	}
+
+	// A zero budget cannot be told apart from a missing one after unmarshalling
+	var stored struct {
+		BudgetCents *int64 `json:"budgetCents"`
+	}
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal project: %v", err)
+	}
+	if stored.BudgetCents == nil {
+		project.BudgetCents = ProjectBudgetCents(project.TotalBudget)
+		upgraded = true
+	}
+
+	return project, upgraded, nil
+}
+
+// InferCertificateType derives the type of a certificate that was stored without one
+func InferCertificateType(certificate *Certificate) string {
+	if certificate.MilestoneID != "" {
+		return CertificateTypeMilestone
+	}
+	return CertificateTypeContract
+}
+
+// BudgetCents converts a budget such as "1500" or "1500.50" to hundredths. ok is false for
+// an empty budget, for one with more than two decimal places or that is not a plain
+// non-negative decimal, and for one too large for an int64.
+func BudgetCents(totalBudget string) (cents int64, ok bool) {
+	whole, fraction, hasPoint := strings.Cut(strings.TrimSpace(totalBudget), ".")
+	if !isDigits(whole) || (hasPoint && !isDigits(fraction)) || len(fraction) > 2 {
+		return 0, false
+	}
+
+	value, ok := new(big.Int).SetString(whole+fraction+strings.Repeat("0", 2-len(fraction)), 10)
+	if !ok || !value.IsInt64() {
+		return 0, false
+	}
+
+	return value.Int64(), true
+}
+
+// ProjectBudgetCents returns the BudgetCents stored for a project with the given budget:
+// the budget in hundredths, or NoBudgetCents if BudgetCents rejects it
+func ProjectBudgetCents(totalBudget string) int64 {
+	cents, ok := BudgetCents(totalBudget)
+	if !ok {
+		return NoBudgetCents
+	}
+	return cents
+}
+
+// isDigits reports whether s is a non-empty string of ASCII decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
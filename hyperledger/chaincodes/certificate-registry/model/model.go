@@ -0,0 +1,86 @@
+// Package model defines the records the certificate-registry chaincode stores on the ledger.
+//
+// Two chaincodes were deployed under the certificate-registry name: the one in
+// hyperledger/chaincodes and an older one from web/chaincode. The older one wrote
+// certificates without contractId, certificateType and ipfsGroupId, and projects
+// without an IPFS group. DecodeCertificate and DecodeProject read records written
+// by either version and normalize them to the current shape.
+package model
+
+// Certificate types
+const (
+	CertificateTypeContract  = "CONTRACT"
+	CertificateTypeMilestone = "MILESTONE"
+)
+
+// StatusUnverified marks certificates whose payment could not be confirmed with escrow,
+// such as those registered through the web registry's RegisterCertificate
+const StatusUnverified = "unverified"
+
+// ProjectDocType marks project documents so CouchDB selectors and indexes
+// can tell them apart from certificates and groups
+const ProjectDocType = "project"
+
+// Certificate represents a certificate stored on the blockchain
+type Certificate struct {
+	CertificateID   string `json:"certificateId"`
+	ProjectID       string `json:"projectId"`
+	ContractID      string `json:"contractId,omitempty" metadata:",optional"`
+	MilestoneID     string `json:"milestoneId,omitempty" metadata:",optional"`
+	IPFSHash        string `json:"ipfsHash"`
+	TransactionHash string `json:"transactionHash"`
+	FreelancerID    string `json:"freelancerId"`
+	ClientID        string `json:"clientId"`
+	Amount          string `json:"amount"`
+	Timestamp       string `json:"timestamp"`
+	Status          string `json:"status"`
+	CertificateType string `json:"certificateType"` // "CONTRACT", "MILESTONE"
+	IPFSGroupID     string `json:"ipfsGroupId"`     // IPFS group this certificate belongs to
+	ContentSHA256   string `json:"contentSha256,omitempty" metadata:",optional"` // hex SHA-256 of the certificate document
+	CIDVersion      int    `json:"cidVersion,omitempty" metadata:",optional"`    // version of IPFSHash; only meaningful when CIDCodec is set
+	CIDCodec        string `json:"cidCodec,omitempty" metadata:",optional"`      // content codec of IPFSHash, e.g. "dag-pb" or "raw"
+	ValidFrom       string `json:"validFrom,omitempty" metadata:",optional"`     // start of validity, RFC 3339 UTC
+	ValidUntil      string `json:"validUntil,omitempty" metadata:",optional"`    // end of validity, RFC 3339 UTC; empty means no expiry
+	Supersedes      string `json:"supersedes,omitempty" metadata:",optional"`    // certificate this one renews
+	SupersededBy    string `json:"supersededBy,omitempty" metadata:",optional"`  // certificate that renewed this one
+	IssuerMSP       string `json:"issuerMsp,omitempty" metadata:",optional"`     // MSP ID of the identity that registered it
+	IssuerID        string `json:"issuerId,omitempty" metadata:",optional"`      // user ID of the identity that registered it, if it has one
+}
+
+// Project represents a project stored on the blockchain
+type Project struct {
+	DocType        string   `json:"docType,omitempty" metadata:",optional"`
+	ProjectID      string   `json:"projectId"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Category       string   `json:"category"`
+	ClientID       string   `json:"clientId"`
+	TotalBudget    string   `json:"totalBudget"`
+	// BudgetCents is TotalBudget in hundredths, a number so CouchDB range selectors compare it
+	// numerically; NoBudgetCents when TotalBudget is empty or not a decimal with at most two places
+	BudgetCents    int64    `json:"budgetCents"`
+	Deadline       string   `json:"deadline,omitempty" metadata:",optional"`
+	SkillsRequired []string `json:"skillsRequired,omitempty" metadata:",optional"`
+	IPFSHash       string   `json:"ipfsHash"`
+	IPFSGroupID    string   `json:"ipfsGroupId"` // IPFS group created for this project
+	RegisteredAt   string   `json:"registeredAt"`
+	Status         string   `json:"status"`
+	// PrivateDetailsHash is the hex SHA-256 of the details kept in the private collection;
+	// Description, TotalBudget and SkillsRequired are then left empty here
+	PrivateDetailsHash string `json:"privateDetailsHash,omitempty" metadata:",optional"`
+}
+
+// IPFSGroup represents an IPFS group for project collaboration
+type IPFSGroup struct {
+	GroupID   string   `json:"groupId"`
+	ProjectID string   `json:"projectId"`
+	IPFSHash  string   `json:"ipfsHash"` // IPFS hash of the group
+	Members   []string `json:"members"`  // List of member IDs (client, freelancers)
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+// GroupID returns the IPFS group ID the registry assigns to a project
+func GroupID(projectID string) string {
+	return "group:" + projectID
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"chaincode-common/access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/verification"
+)
+
+// certificateChaincodeName is the name this chaincode is normally deployed under, used when
+// the proposal does not say
+const certificateChaincodeName = "certificate-registry"
+
+// GetVerificationPayload returns the payload to print on a certificate as a QR code. The issuer
+// signs it off-chain with the verification package; scanners check the signature and can then
+// call VerifyCertificate with the certificate ID and IPFS hash it carries.
+func (s *CertificateContract) GetVerificationPayload(ctx contractapi.TransactionContextInterface, certificateId string) (*verification.Payload, error) {
+	certificate, err := s.GetCertificate(ctx, certificateId)
+	if err != nil {
+		return nil, err
+	}
+
+	chaincodeName, _, err := getProposalInvocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if chaincodeName == "" {
+		chaincodeName = certificateChaincodeName
+	}
+
+	return &verification.Payload{
+		Channel:       ctx.GetStub().GetChannelID(),
+		Chaincode:     chaincodeName,
+		CertificateID: certificate.CertificateID,
+		IPFSHash:      certificate.IPFSHash,
+		ContentSHA256: certificate.ContentSHA256,
+		Issuer:        certificate.IssuerMSP,
+		IssuedAt:      certificate.Timestamp,
+	}, nil
+}
+
+// setIssuer records the MSP and user ID of the identity registering a certificate. When escrow
+// registers it in a chaincode-to-chaincode call this is the client that invoked escrow.
+// Identities without a user ID, such as cryptogen admins, are recorded by MSP only.
+func setIssuer(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	certificate.IssuerMSP = mspId
+	if issuerId, err := access.GetCallerID(ctx); err == nil {
+		certificate.IssuerID = issuerId
+	}
+	return nil
+}
+
+// requireIssuer fails unless the caller is an admin or the identity that registered the certificate
+func requireIssuer(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	if access.RequireAdmin(ctx) == nil {
+		return nil
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	callerId, err := access.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	if certificate.IssuerID == "" || certificate.IssuerID != callerId || certificate.IssuerMSP != mspId {
+		return fmt.Errorf("only the issuer of certificate %s or an admin can change it", certificate.CertificateID)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
+)
+
+// PortfolioEntry is a certificate joined with the project it was issued for
+type PortfolioEntry struct {
+	Certificate     *Certificate `json:"certificate"`
+	ProjectTitle    string       `json:"projectTitle"`
+	ProjectCategory string       `json:"projectCategory"`
+}
+
+// FreelancerPortfolio lists every certificate a freelancer earned with summed amounts.
+// Contract and milestone amounts are summed separately because milestone payments
+// are part of the contract value and adding both would count the same money twice.
+type FreelancerPortfolio struct {
+	FreelancerID         string            `json:"freelancerId"`
+	Certificates         []*PortfolioEntry `json:"certificates"`
+	CertificateCount     int               `json:"certificateCount"`
+	TotalContractAmount  string            `json:"totalContractAmount"`
+	TotalMilestoneAmount string            `json:"totalMilestoneAmount"`
+}
+
+// GetCertificatesByFreelancer returns the verifiable portfolio of a freelancer
+func (s *CertificateContract) GetCertificatesByFreelancer(ctx contractapi.TransactionContextInterface, freelancerId string) (*FreelancerPortfolio, error) {
+	if freelancerId == "" {
+		return nil, fmt.Errorf("freelancerId is required")
+	}
+
+	certificates, err := s.getCertificatesByIndex(ctx, "freelancer~certificate", freelancerId)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolio := &FreelancerPortfolio{
+		FreelancerID: freelancerId,
+		Certificates: []*PortfolioEntry{},
+	}
+
+	projects := map[string]*Project{}
+	var contractAmounts, milestoneAmounts []string
+	for _, certificate := range certificates {
+		project, ok := projects[certificate.ProjectID]
+		if !ok {
+			// A missing project should not hide the certificate itself
+			project, err = s.GetProject(ctx, certificate.ProjectID)
+			if err != nil {
+				project = &Project{}
+			}
+			projects[certificate.ProjectID] = project
+		}
+
+		portfolio.Certificates = append(portfolio.Certificates, &PortfolioEntry{
+			Certificate:     certificate,
+			ProjectTitle:    project.Title,
+			ProjectCategory: project.Category,
+		})
+
+		// Amounts that escrow never confirmed are listed but not counted
+		if certificate.Status == model.StatusUnverified {
+			continue
+		}
+
+		switch certificate.CertificateType {
+		case "CONTRACT":
+			contractAmounts = append(contractAmounts, certificate.Amount)
+		case "MILESTONE":
+			milestoneAmounts = append(milestoneAmounts, certificate.Amount)
+		}
+	}
+
+	portfolio.CertificateCount = len(portfolio.Certificates)
+
+	portfolio.TotalContractAmount, err = sumAmounts(contractAmounts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum contract amounts: %v", err)
+	}
+
+	portfolio.TotalMilestoneAmount, err = sumAmounts(milestoneAmounts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum milestone amounts: %v", err)
+	}
+
+	return portfolio, nil
+}
+
+// GetCertificatesByClient returns all certificates issued for a client's projects
+func (s *CertificateContract) GetCertificatesByClient(ctx contractapi.TransactionContextInterface, clientId string) ([]*Certificate, error) {
+	if clientId == "" {
+		return nil, fmt.Errorf("clientId is required")
+	}
+
+	return s.getCertificatesByIndex(ctx, "client~certificate", clientId)
+}
+
+// getCertificatesByIndex resolves a value~certificate composite index to certificates
+func (s *CertificateContract) getCertificatesByIndex(ctx contractapi.TransactionContextInterface, indexName string, value string) ([]*Certificate, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(indexName, []string{value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificates from %s index: %v", indexName, err)
+	}
+	defer resultsIterator.Close()
+
+	certificates := []*Certificate{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next certificate: %v", err)
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+
+		certificate, err := s.GetCertificate(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
+
+// putCertificatePartyIndexes writes the freelancer~certificate and client~certificate composite keys
+func putCertificatePartyIndexes(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	if certificate.FreelancerID != "" {
+		err := putCompositeIndex(ctx, "freelancer~certificate", certificate.FreelancerID, certificate.CertificateID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if certificate.ClientID != "" {
+		err := putCompositeIndex(ctx, "client~certificate", certificate.ClientID, certificate.CertificateID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// delCertificatePartyIndexes removes the freelancer~certificate and client~certificate composite keys
+func delCertificatePartyIndexes(ctx contractapi.TransactionContextInterface, certificate *Certificate) error {
+	parties := []struct{ indexName, partyId string }{
+		{"freelancer~certificate", certificate.FreelancerID},
+		{"client~certificate", certificate.ClientID},
+	}
+
+	for _, party := range parties {
+		if party.partyId == "" {
+			continue
+		}
+
+		indexName := party.indexName
+		indexKey, err := ctx.GetStub().CreateCompositeKey(indexName, []string{party.partyId, certificate.CertificateID})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+
+		err = ctx.GetStub().DelState(indexKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s relationship: %v", indexName, err)
+		}
+	}
+
+	return nil
+}
+
+// parseDecimal parses a non-negative decimal amount such as "1500" or "1500.50"
+func parseDecimal(amount string) (*big.Rat, int, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return new(big.Rat), 0, nil
+	}
+
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || value.Sign() < 0 || strings.ContainsAny(amount, "eE/") {
+		return nil, 0, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	scale := 0
+	if i := strings.Index(amount, "."); i >= 0 {
+		scale = len(amount) - i - 1
+	}
+
+	return value, scale, nil
+}
+
+// sumAmounts adds decimal amount strings exactly, keeping the largest input precision
+func sumAmounts(amounts []string) (string, error) {
+	total := new(big.Rat)
+	maxScale := 0
+	for _, amount := range amounts {
+		// Certificates written by the web registry may have no amount
+		if amount == "" {
+			continue
+		}
+
+		value, scale, err := parseDecimal(amount)
+		if err != nil {
+			return "", err
+		}
+		total.Add(total, value)
+		if scale > maxScale {
+			maxScale = scale
+		}
+	}
+
+	return total.FloatString(maxScale), nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// positionalFields maps each transaction that used to read GetStringArgs by position
+// to the JSON field names of its arguments, in order. A transaction may have several
+// forms, told apart by their number of arguments.
+//
+// Deprecated: the positional form is kept only for existing clients; send a single
+// JSON object instead.
+var positionalFields = map[string][][]string{
+	"RegisterProject": {
+		{"projectId", "title", "description", "category", "clientId", "totalBudget", "deadline", "skillsRequired", "ipfsHash", "ipfsGroupHash"},
+	},
+	"RegisterContractCertificate": {
+		{"certificateId", "projectId", "contractId", "ipfsHash", "transactionHash", "freelancerId", "clientId", "amount"},
+	},
+	"RegisterMilestoneCertificate": {
+		{"certificateId", "projectId", "contractId", "milestoneId", "ipfsHash", "transactionHash", "freelancerId", "clientId", "amount"},
+	},
+}
+
+// positionalChaincode wraps the contract chaincode and rewrites deprecated positional
+// invocations into the single JSON input the transactions now take, so both forms go
+// through the same schema validation
+type positionalChaincode struct {
+	*contractapi.ContractChaincode
+}
+
+// Init handles instantiate-time invocations like Invoke
+func (cc *positionalChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	function, _ := stub.GetFunctionAndParameters()
+	if function == "" {
+		return cc.ContractChaincode.Init(stub)
+	}
+
+	return cc.Invoke(stub)
+}
+
+// Invoke converts positional arguments to a JSON input before dispatching
+func (cc *positionalChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, params := stub.GetFunctionAndParameters()
+
+	name := function
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	forms, ok := positionalFields[name]
+	if !ok || len(params) <= 1 {
+		return cc.ContractChaincode.Invoke(stub)
+	}
+
+	input, fields, err := positionalToJSON(forms, params)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s: %v", name, err))
+	}
+
+	return cc.ContractChaincode.Invoke(&rewrittenArgsStub{
+		ChaincodeStubInterface: stub,
+		args:                   [][]byte{[]byte(function), input},
+		fields:                 fields,
+	})
+}
+
+// positionalForm returns the argument names of the positional form the transaction was
+// invoked with, or nil if it was invoked with a JSON input
+func positionalForm(ctx contractapi.TransactionContextInterface) []string {
+	stub, ok := ctx.GetStub().(*rewrittenArgsStub)
+	if !ok {
+		return nil
+	}
+	return stub.fields
+}
+
+// positionalToJSON builds the JSON input object from positional arguments,
+// using the form that takes as many arguments as were passed, and returns that form
+func positionalToJSON(forms [][]string, params []string) ([]byte, []string, error) {
+	var fields []string
+	var counts []string
+	for _, form := range forms {
+		if len(form) == len(params) {
+			fields = form
+			break
+		}
+		counts = append(counts, strconv.Itoa(len(form)))
+	}
+	if fields == nil {
+		return nil, nil, fmt.Errorf("incorrect number of arguments. Expecting %s, got %d", strings.Join(counts, " or "), len(params))
+	}
+
+	input := map[string]interface{}{}
+	for i, field := range fields {
+		if field != "skillsRequired" {
+			input[field] = params[i]
+			continue
+		}
+
+		// Skills were passed as a JSON array string; unparsable values meant no skills
+		skills := []string{}
+		if params[i] != "" && params[i] != "[]" {
+			err := json.Unmarshal([]byte(params[i]), &skills)
+			if err != nil {
+				skills = []string{}
+			}
+		}
+		input[field] = skills
+	}
+
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return inputJSON, fields, nil
+}
+
+// rewrittenArgsStub is a stub whose invocation arguments have been replaced
+type rewrittenArgsStub struct {
+	shim.ChaincodeStubInterface
+	args   [][]byte
+	fields []string // positional form the arguments were converted from
+}
+
+// GetArgs returns the rewritten arguments
+func (s *rewrittenArgsStub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the rewritten arguments as strings
+func (s *rewrittenArgsStub) GetStringArgs() []string {
+	strArgs := make([]string, 0, len(s.args))
+	for _, arg := range s.args {
+		strArgs = append(strArgs, string(arg))
+	}
+	return strArgs
+}
+
+// GetFunctionAndParameters returns the function name and rewritten parameters
+func (s *rewrittenArgsStub) GetFunctionAndParameters() (string, []string) {
+	strArgs := s.GetStringArgs()
+	if len(strArgs) == 0 {
+		return "", []string{}
+	}
+	return strArgs[0], strArgs[1:]
+}
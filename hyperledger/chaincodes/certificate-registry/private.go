@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"chaincode-common/access"
+)
+
+// projectPrivateCollection holds project details that must not be visible to every
+// organization on the channel; see collections_config.json
+const projectPrivateCollection = "projectPrivateDetails"
+
+// transientProjectPrivate is the transient map key RegisterProject reads private details from
+const transientProjectPrivate = "projectPrivate"
+
+// minPrivateSaltLength is the fewest bytes of salt private details must carry
+const minPrivateSaltLength = 16
+
+// ProjectPrivateDetails are the project fields kept in the private collection
+type ProjectPrivateDetails struct {
+	ProjectID      string   `json:"projectId"`
+	Description    string   `json:"description,omitempty" metadata:",optional"`
+	TotalBudget    string   `json:"totalBudget,omitempty" metadata:",optional"`
+	SkillsRequired []string `json:"skillsRequired,omitempty" metadata:",optional"`
+	Salt           string   `json:"salt,omitempty" metadata:",optional"` // random value so the public hash cannot be guessed from likely details; required on input
+}
+
+// GetProjectPrivateDetails returns the private details of a project to its client, to the
+// client and freelancer of its funded escrow contracts and to admins. It must be sent to a
+// peer of an organization in the collection.
+func (s *CertificateContract) GetProjectPrivateDetails(ctx contractapi.TransactionContextInterface, projectId string) (*ProjectPrivateDetails, error) {
+	project, err := s.GetProject(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+	if project.PrivateDetailsHash == "" {
+		return nil, fmt.Errorf("project %s has no private details", projectId)
+	}
+
+	// Only the project's client, the parties of its confirmed escrow contracts and admins may read them
+	if access.RequireAdmin(ctx) != nil {
+		callerId, err := access.GetCallerID(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		isParty, err := isProjectParty(ctx, project, callerId)
+		if err != nil {
+			return nil, err
+		}
+		if !isParty {
+			return nil, fmt.Errorf("caller %s is not a party of project %s", callerId, projectId)
+		}
+	}
+
+	detailsJSON, err := ctx.GetStub().GetPrivateData(projectPrivateCollection, "project:"+projectId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private details: %v", err)
+	}
+	if detailsJSON == nil {
+		return nil, fmt.Errorf("private details of project %s are not available on this peer", projectId)
+	}
+
+	// The public hash detects a peer serving details other than the ones registered
+	if privateDetailsHash(detailsJSON) != project.PrivateDetailsHash {
+		return nil, fmt.Errorf("private details of project %s do not match the registered hash", projectId)
+	}
+
+	var details ProjectPrivateDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal private details: %v", err)
+	}
+
+	return &details, nil
+}
+
+// isProjectParty reports whether the caller is the project's client or the client or freelancer
+// of one of its escrow contracts. Contracts still CREATED are skipped: escrow confirms the
+// agreement with the first LockFunds.
+func isProjectParty(ctx contractapi.TransactionContextInterface, project *Project, callerId string) (bool, error) {
+	if project.ClientID != "" && callerId == project.ClientID {
+		return true, nil
+	}
+
+	contracts, err := getEscrowContractsByProject(ctx, project.ProjectID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, contract := range contracts {
+		if contract.Status == "CREATED" {
+			continue
+		}
+		if callerId == contract.ClientAddress || callerId == contract.FreelancerAddress {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getProjectPrivateInput reads the private details sent with RegisterProject, or nil if
+// the project is registered with public details only
+func getProjectPrivateInput(ctx contractapi.TransactionContextInterface, projectId string) (*ProjectPrivateDetails, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	detailsJSON, ok := transientMap[transientProjectPrivate]
+	if !ok {
+		return nil, nil
+	}
+
+	var details ProjectPrivateDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return nil, fmt.Errorf("transient field %s is not valid JSON: %v", transientProjectPrivate, err)
+	}
+	if details.ProjectID != "" && details.ProjectID != projectId {
+		return nil, fmt.Errorf("transient field %s is for project %s, not %s", transientProjectPrivate, details.ProjectID, projectId)
+	}
+	if len(details.Salt) < minPrivateSaltLength {
+		return nil, fmt.Errorf("transient field %s must carry a salt of at least %d bytes", transientProjectPrivate, minPrivateSaltLength)
+	}
+	details.ProjectID = projectId
+
+	return &details, nil
+}
+
+// putProjectPrivateDetails stores private details in the collection and returns the hash
+// to record publicly
+func putProjectPrivateDetails(ctx contractapi.TransactionContextInterface, details *ProjectPrivateDetails) (string, error) {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private details: %v", err)
+	}
+
+	err = ctx.GetStub().PutPrivateData(projectPrivateCollection, "project:"+details.ProjectID, detailsJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to put private details: %v", err)
+	}
+
+	return privateDetailsHash(detailsJSON), nil
+}
+
+// privateDetailsHash returns the hex SHA-256 of stored private details
+func privateDetailsHash(detailsJSON []byte) string {
+	digest := sha256.Sum256(detailsJSON)
+	return hex.EncodeToString(digest[:])
+}
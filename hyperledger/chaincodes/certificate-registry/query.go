@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
+)
+
+// projectDocType marks project documents so CouchDB selectors and indexes
+// can tell them apart from certificates and groups
+const projectDocType = model.ProjectDocType
+
+// maxQueryPageSize bounds the page size accepted by paginated queries
+const maxQueryPageSize = 100
+
+// ProjectQueryResult is a page of projects returned by QueryProjects
+type ProjectQueryResult struct {
+	Records             []*Project `json:"records"`
+	FetchedRecordsCount int32      `json:"fetchedRecordsCount"`
+	Bookmark            string     `json:"bookmark"`
+}
+
+// QueryProjects runs a CouchDB rich query over projects and returns one page of results.
+// selectorJSON is either a Mango selector ({"category": "Web Development"}) or a full
+// query object with a "selector" field; it is always restricted to project documents.
+// Filter budgets on the numeric budgetCents with a lower bound, e.g. {"budgetCents": {"$gte": 0, "$lt": 500000}};
+// projects without a usable budget store -1 there.
+// A "fields" projection is rejected, since every result is decoded as a whole project.
+// Requires CouchDB as the state database; use GetProjectsByCategory or GetProjectsBySkill on LevelDB.
+func (s *CertificateContract) QueryProjects(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (*ProjectQueryResult, error) {
+	if pageSize <= 0 || pageSize > maxQueryPageSize {
+		return nil, fmt.Errorf("pageSize must be between 1 and %d", maxQueryPageSize)
+	}
+
+	queryString, err := buildProjectQuery(selectorJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query projects: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	projects := []*Project{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next project: %v", err)
+		}
+
+		project, _, err := model.DecodeProject(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, project)
+	}
+
+	return &ProjectQueryResult{
+		Records:             projects,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// GetProjectsByCategory returns all projects registered under a category
+func (s *CertificateContract) GetProjectsByCategory(ctx contractapi.TransactionContextInterface, category string) ([]*Project, error) {
+	if category == "" {
+		return nil, fmt.Errorf("category is required")
+	}
+
+	return s.getProjectsByIndex(ctx, "category~project", normalizeIndexValue(category))
+}
+
+// GetProjectsBySkill returns all projects that list the given skill as required
+func (s *CertificateContract) GetProjectsBySkill(ctx contractapi.TransactionContextInterface, skill string) ([]*Project, error) {
+	if skill == "" {
+		return nil, fmt.Errorf("skill is required")
+	}
+
+	return s.getProjectsByIndex(ctx, "skill~project", normalizeIndexValue(skill))
+}
+
+// getProjectsByIndex resolves a value~project composite index to project records
+func (s *CertificateContract) getProjectsByIndex(ctx contractapi.TransactionContextInterface, indexName string, value string) ([]*Project, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(indexName, []string{value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects from %s index: %v", indexName, err)
+	}
+	defer resultsIterator.Close()
+
+	projects := []*Project{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next project: %v", err)
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+
+		project, err := s.GetProject(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, project)
+	}
+
+	return projects, nil
+}
+
+// putProjectIndexes writes the category~project and skill~project composite keys for a project
+func putProjectIndexes(ctx contractapi.TransactionContextInterface, project *Project) error {
+	for _, index := range projectIndexes(project) {
+		err := putCompositeIndex(ctx, index.name, index.attributes...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putCompositeIndex stores an index entry with the conventional 0x00 placeholder value
+func putCompositeIndex(ctx contractapi.TransactionContextInterface, indexName string, attributes ...string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(indexName, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put %s index: %v", indexName, err)
+	}
+
+	return nil
+}
+
+// normalizeIndexValue makes index lookups case- and whitespace-insensitive
+func normalizeIndexValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// buildProjectQuery wraps a caller supplied selector so it only matches project documents
+func buildProjectQuery(selectorJSON string) (string, error) {
+	query := map[string]interface{}{}
+	if strings.TrimSpace(selectorJSON) != "" {
+		err := json.Unmarshal([]byte(selectorJSON), &query)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse selector: %v", err)
+		}
+	}
+
+	selector, ok := query["selector"].(map[string]interface{})
+	if !ok {
+		if _, present := query["selector"]; present {
+			return "", fmt.Errorf("selector must be a JSON object")
+		}
+		selector = query
+		query = map[string]interface{}{}
+	}
+
+	// Partial documents would decode as projects with empty fields
+	if _, present := query["fields"]; present {
+		return "", fmt.Errorf("fields is not supported; QueryProjects returns whole projects")
+	}
+
+	selector["docType"] = projectDocType
+	query["selector"] = selector
+
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query: %v", err)
+	}
+
+	return string(queryJSON), nil
+}
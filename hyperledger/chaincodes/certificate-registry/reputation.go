@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chaincode-common/access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
+)
+
+// ReputationContract derives auditable reputation scores from ledger data.
+// It is registered in the certificate-registry chaincode under the "reputation" namespace,
+// so its transactions are invoked as "reputation:GetReputation".
+type ReputationContract struct {
+	contractapi.Contract
+}
+
+// Reputation is a user's score together with the inputs it was computed from
+type Reputation struct {
+	UserID                string `json:"userId"`
+	Score                 int    `json:"score"`
+	CompletedContracts    int    `json:"completedContracts"`
+	MilestoneCertificates int    `json:"milestoneCertificates"`
+	DisputesLost          int    `json:"disputesLost"`
+	ReviewCount           int    `json:"reviewCount"`
+	ReviewScoreTotal      int    `json:"reviewScoreTotal"`
+	ComputedAt            string `json:"computedAt"` // empty when computed on read and not yet stored
+}
+
+// Dispute records the outcome of a resolved project dispute
+type Dispute struct {
+	DisputeID          string `json:"disputeId"`
+	ProjectID          string `json:"projectId"`
+	WinnerID           string `json:"winnerId"`
+	LoserID            string `json:"loserId"`
+	ResolutionIPFSHash string `json:"resolutionIpfsHash"`
+	ResolvedAt         string `json:"resolvedAt"`
+}
+
+// Score weights. Every user starts at baseReputation and the result is clamped
+// to [minReputation, maxReputation]. Only integer math is used so every peer
+// computes the same score.
+const (
+	baseReputation          = 500
+	minReputation           = 0
+	maxReputation           = 1000
+	completedContractWeight = 50
+	milestoneWeight         = 10
+	disputeLostPenalty      = 100
+	reviewWeightPerStar     = 20
+	neutralReviewScore      = 3
+)
+
+// GetReputation returns the stored reputation of a user, or computes it on the fly
+// if RecomputeReputation has not been run for the user yet
+func (r *ReputationContract) GetReputation(ctx contractapi.TransactionContextInterface, userId string) (*Reputation, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId is required")
+	}
+
+	reputationKey, err := ctx.GetStub().CreateCompositeKey("reputation", []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	reputationJSON, err := ctx.GetStub().GetState(reputationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reputation: %v", err)
+	}
+	if reputationJSON == nil {
+		return computeReputation(ctx, userId)
+	}
+
+	var reputation Reputation
+	err = json.Unmarshal(reputationJSON, &reputation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reputation: %v", err)
+	}
+
+	return &reputation, nil
+}
+
+// RecomputeReputation recomputes a user's reputation from the ledger and stores it (admin only)
+func (r *ReputationContract) RecomputeReputation(ctx contractapi.TransactionContextInterface, userId string) (*Reputation, error) {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if userId == "" {
+		return nil, fmt.Errorf("userId is required")
+	}
+
+	reputation, err := computeReputation(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	reputation.ComputedAt = txTimestamp.AsTime().Format("2006-01-02T15:04:05Z")
+
+	reputationJSON, err := json.Marshal(reputation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reputation: %v", err)
+	}
+
+	reputationKey, err := ctx.GetStub().CreateCompositeKey("reputation", []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(reputationKey, reputationJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put reputation to state: %v", err)
+	}
+
+	return reputation, nil
+}
+
+// RecordDisputeOutcome records which party lost a dispute on a project (admin only)
+func (r *ReputationContract) RecordDisputeOutcome(ctx contractapi.TransactionContextInterface, disputeId string, projectId string, winnerId string, loserId string, resolutionIpfsHash string) error {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if disputeId == "" || projectId == "" || loserId == "" {
+		return fmt.Errorf("disputeId, projectId, and loserId are required")
+	}
+
+	disputeKey, err := ctx.GetStub().CreateCompositeKey("dispute", []string{disputeId})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	disputeJSON, err := ctx.GetStub().GetState(disputeKey)
+	if err != nil {
+		return fmt.Errorf("failed to read dispute: %v", err)
+	}
+	if disputeJSON != nil {
+		return fmt.Errorf("dispute %s already exists", disputeId)
+	}
+
+	// Disputes can only be recorded for projects known to the registry
+	_, err = (&CertificateContract{}).GetProject(ctx, projectId)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	dispute := Dispute{
+		DisputeID:          disputeId,
+		ProjectID:          projectId,
+		WinnerID:           winnerId,
+		LoserID:            loserId,
+		ResolutionIPFSHash: resolutionIpfsHash,
+		ResolvedAt:         txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
+	}
+
+	disputeJSON, err = json.Marshal(dispute)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispute: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(disputeKey, disputeJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put dispute to state: %v", err)
+	}
+
+	return putCompositeIndex(ctx, "loser~dispute", loserId, disputeId)
+}
+
+// computeReputation derives a reputation from certificates, disputes and reviews on the ledger.
+// A certificate only counts when escrow confirms it: its contract must exist in escrow for the
+// same project and parties, and for a MILESTONE certificate the milestone must be released.
+// Each escrow contract and each milestone counts once, however many certificates name it.
+func computeReputation(ctx contractapi.TransactionContextInterface, userId string) (*Reputation, error) {
+	registry := &CertificateContract{}
+	reputation := &Reputation{UserID: userId}
+
+	contracts := map[string]*escrowContract{}
+	projectStatus := map[string]string{}
+	counted := map[string]bool{}
+	for _, indexName := range []string{"freelancer~certificate", "client~certificate"} {
+		certificates, err := registry.getCertificatesByIndex(ctx, indexName, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, certificate := range certificates {
+			if certificate.Status == model.StatusUnverified || certificate.ContractID == "" {
+				continue
+			}
+
+			contract, ok := contracts[certificate.ContractID]
+			if !ok {
+				// A contract escrow does not know confirms nothing
+				contract, err = getEscrowContract(ctx, certificate.ContractID)
+				if err != nil {
+					contract = nil
+				}
+				contracts[certificate.ContractID] = contract
+			}
+			if contract == nil || contract.ProjectID != certificate.ProjectID ||
+				contract.FreelancerAddress != certificate.FreelancerID || contract.ClientAddress != certificate.ClientID {
+				continue
+			}
+
+			switch certificate.CertificateType {
+			case model.CertificateTypeContract:
+				if counted[contract.ContractID] {
+					continue
+				}
+				status, ok := projectStatus[certificate.ProjectID]
+				if !ok {
+					project, err := registry.GetProject(ctx, certificate.ProjectID)
+					if err == nil {
+						status = project.Status
+					}
+					projectStatus[certificate.ProjectID] = status
+				}
+				if contract.Status == "COMPLETED" || status == "completed" {
+					counted[contract.ContractID] = true
+					reputation.CompletedContracts++
+				}
+			case model.CertificateTypeMilestone:
+				milestoneKey := contract.ContractID + "/" + certificate.MilestoneID
+				if counted[milestoneKey] || (certificate.Status != "active" && certificate.Status != "completed") {
+					continue
+				}
+				if isMilestoneReleased(contract, certificate.MilestoneID) {
+					counted[milestoneKey] = true
+					reputation.MilestoneCertificates++
+				}
+			}
+		}
+	}
+
+	disputesIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("loser~dispute", []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disputes for user: %v", err)
+	}
+	defer disputesIterator.Close()
+
+	for disputesIterator.HasNext() {
+		_, err := disputesIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next dispute: %v", err)
+		}
+		reputation.DisputesLost++
+	}
+
+	reviews, err := registry.GetReviewsFor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	reputation.ReviewCount = reviews.ReviewCount
+	reputation.ReviewScoreTotal = reviews.TotalScore
+
+	score := baseReputation +
+		reputation.CompletedContracts*completedContractWeight +
+		reputation.MilestoneCertificates*milestoneWeight -
+		reputation.DisputesLost*disputeLostPenalty +
+		(reputation.ReviewScoreTotal-reputation.ReviewCount*neutralReviewScore)*reviewWeightPerStar
+
+	if score < minReputation {
+		score = minReputation
+	}
+	if score > maxReputation {
+		score = maxReputation
+	}
+	reputation.Score = score
+
+	return reputation, nil
+}
+
+// isMilestoneReleased reports whether escrow has released a milestone of a contract
+func isMilestoneReleased(contract *escrowContract, milestoneId string) bool {
+	for _, milestone := range contract.Milestones {
+		if milestone.MilestoneID == milestoneId {
+			return milestone.Status == "RELEASED"
+		}
+	}
+	return false
+}
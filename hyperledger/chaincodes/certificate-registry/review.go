@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chaincode-common/access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Review is a rating one project group member gives another at the end of a project
+type Review struct {
+	ProjectID       string `json:"projectId"`
+	ReviewerID      string `json:"reviewerId"`
+	RevieweeID      string `json:"revieweeId"`
+	Score           int    `json:"score"` // 1 to 5
+	CommentIPFSHash string `json:"commentIpfsHash"`
+	SubmittedAt     string `json:"submittedAt"`
+}
+
+// ReviewSummary aggregates all reviews received by a user
+type ReviewSummary struct {
+	UserID       string    `json:"userId"`
+	Reviews      []*Review `json:"reviews"`
+	ReviewCount  int       `json:"reviewCount"`
+	TotalScore   int       `json:"totalScore"`
+	AverageScore string    `json:"averageScore"` // two decimal places, "0.00" without reviews
+}
+
+const (
+	minReviewScore = 1
+	maxReviewScore = 5
+)
+
+// SubmitReview records a review from the calling group member for another member of a completed project.
+// The reviewer is taken from the caller's identity (the "userId" attribute, or the enrollment ID),
+// and each reviewer may review each reviewee once per project.
+func (s *CertificateContract) SubmitReview(ctx contractapi.TransactionContextInterface, projectId string, revieweeId string, score int, commentIpfsHash string) error {
+	if projectId == "" || revieweeId == "" {
+		return fmt.Errorf("projectId and revieweeId are required")
+	}
+
+	if score < minReviewScore || score > maxReviewScore {
+		return fmt.Errorf("score must be between %d and %d", minReviewScore, maxReviewScore)
+	}
+
+	reviewerId, err := access.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if reviewerId == revieweeId {
+		return fmt.Errorf("users cannot review themselves")
+	}
+
+	project, err := s.GetProject(ctx, projectId)
+	if err != nil {
+		return err
+	}
+
+	if project.Status != "completed" {
+		return fmt.Errorf("project %s is not completed", projectId)
+	}
+
+	group, err := s.GetIPFSGroup(ctx, projectId)
+	if err != nil {
+		return err
+	}
+
+	if !isGroupMember(group, reviewerId) {
+		return fmt.Errorf("reviewer %s is not a member of the project group", reviewerId)
+	}
+	if !isGroupMember(group, revieweeId) {
+		return fmt.Errorf("reviewee %s is not a member of the project group", revieweeId)
+	}
+
+	// Check if the reviewer already reviewed this reviewee on this project
+	reviewKey, err := ctx.GetStub().CreateCompositeKey("review", []string{projectId, reviewerId, revieweeId})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	reviewJSON, err := ctx.GetStub().GetState(reviewKey)
+	if err != nil {
+		return fmt.Errorf("failed to read review: %v", err)
+	}
+	if reviewJSON != nil {
+		return fmt.Errorf("%s has already reviewed %s for project %s", reviewerId, revieweeId, projectId)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	review := Review{
+		ProjectID:       projectId,
+		ReviewerID:      reviewerId,
+		RevieweeID:      revieweeId,
+		Score:           score,
+		CommentIPFSHash: commentIpfsHash,
+		SubmittedAt:     txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
+	}
+
+	reviewJSON, err = json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("failed to marshal review: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(reviewKey, reviewJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put review to state: %v", err)
+	}
+
+	// Index by reviewee so all reviews for a user can be read at once
+	return putCompositeIndex(ctx, "reviewee~review", revieweeId, projectId, reviewerId)
+}
+
+// GetReviewsFor returns all reviews a user received with the aggregate score
+func (s *CertificateContract) GetReviewsFor(ctx contractapi.TransactionContextInterface, userId string) (*ReviewSummary, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId is required")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("reviewee~review", []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews for user: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	summary := &ReviewSummary{
+		UserID:  userId,
+		Reviews: []*Review{},
+	}
+
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next review: %v", err)
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+
+		projectId, reviewerId := compositeKeyParts[1], compositeKeyParts[2]
+		reviewKey, err := ctx.GetStub().CreateCompositeKey("review", []string{projectId, reviewerId, userId})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create composite key: %v", err)
+		}
+
+		reviewJSON, err := ctx.GetStub().GetState(reviewKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read review: %v", err)
+		}
+		if reviewJSON == nil {
+			continue
+		}
+
+		var review Review
+		err = json.Unmarshal(reviewJSON, &review)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal review: %v", err)
+		}
+
+		summary.Reviews = append(summary.Reviews, &review)
+		summary.TotalScore += review.Score
+	}
+
+	summary.ReviewCount = len(summary.Reviews)
+	summary.AverageScore = formatAverage(summary.TotalScore, summary.ReviewCount)
+
+	return summary, nil
+}
+
+// isGroupMember reports whether userId is a member of the IPFS group
+func isGroupMember(group *IPFSGroup, userId string) bool {
+	for _, member := range group.Members {
+		if member == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAverage divides with integer math and renders two decimal places, rounding half up
+func formatAverage(total int, count int) string {
+	if count == 0 {
+		return "0.00"
+	}
+
+	hundredths := (total*200 + count) / (count * 2)
+	return fmt.Sprintf("%d.%02d", hundredths/100, hundredths%100)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
+)
+
+// Verification statuses reported by VerifyCertificate
+const (
+	VerificationValid        = "valid"
+	VerificationHashMismatch = "hash_mismatch"
+	VerificationNotYetValid  = "not_yet_valid"
+	VerificationExpired      = "expired"
+	VerificationSuperseded   = "superseded"
+)
+
+// validityTimeFormat is the layout validity bounds are stored in, the same as other timestamps
+const validityTimeFormat = "2006-01-02T15:04:05Z"
+
+// CertificateVerification is the result of VerifyCertificate
+type CertificateVerification struct {
+	CertificateID string `json:"certificateId"`
+	Valid         bool   `json:"valid"`
+	Status        string `json:"status"` // one of valid, hash_mismatch, not_yet_valid, expired, superseded
+	ValidFrom     string `json:"validFrom,omitempty" metadata:",optional"`
+	ValidUntil    string `json:"validUntil,omitempty" metadata:",optional"`
+	SupersededBy  string `json:"supersededBy,omitempty" metadata:",optional"`
+	CheckedAt     string `json:"checkedAt"` // transaction timestamp the validity window was checked against
+}
+
+// verifyCertificate checks a certificate against a document hash and its validity window at the
+// transaction timestamp. A renewed certificate is superseded once its successor takes effect.
+func verifyCertificate(ctx contractapi.TransactionContextInterface, certificate *Certificate, ipfsHash string) (*CertificateVerification, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	now := txTimestamp.AsTime().UTC()
+
+	verification := &CertificateVerification{
+		CertificateID: certificate.CertificateID,
+		ValidFrom:     certificate.ValidFrom,
+		ValidUntil:    certificate.ValidUntil,
+		SupersededBy:  certificate.SupersededBy,
+		CheckedAt:     now.Format(validityTimeFormat),
+	}
+
+	status, err := validityStatus(certificate, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == VerificationValid && certificate.SupersededBy != "" {
+		successorJSON, _, err := getCertificateState(ctx, certificate.SupersededBy)
+		if err != nil {
+			return nil, err
+		}
+		if successorJSON != nil {
+			successor, _, err := model.DecodeCertificate(successorJSON)
+			if err != nil {
+				return nil, err
+			}
+			successorStatus, err := validityStatus(successor, now)
+			if err != nil {
+				return nil, err
+			}
+			if successorStatus != VerificationNotYetValid {
+				status = VerificationSuperseded
+			}
+		}
+	}
+
+	// A document that does not match is reported before anything about the window
+	if !sameCID(certificate.IPFSHash, ipfsHash) {
+		status = VerificationHashMismatch
+	}
+
+	verification.Status = status
+	verification.Valid = status == VerificationValid
+	return verification, nil
+}
+
+// validityStatus places a time in the validity window of a certificate; certificates without
+// bounds are always valid
+func validityStatus(certificate *Certificate, now time.Time) (string, error) {
+	if certificate.ValidFrom != "" {
+		validFrom, err := time.Parse(validityTimeFormat, certificate.ValidFrom)
+		if err != nil {
+			return "", fmt.Errorf("certificate %s has an invalid validFrom %s: %v", certificate.CertificateID, certificate.ValidFrom, err)
+		}
+		if now.Before(validFrom) {
+			return VerificationNotYetValid, nil
+		}
+	}
+
+	if certificate.ValidUntil != "" {
+		validUntil, err := time.Parse(validityTimeFormat, certificate.ValidUntil)
+		if err != nil {
+			return "", fmt.Errorf("certificate %s has an invalid validUntil %s: %v", certificate.CertificateID, certificate.ValidUntil, err)
+		}
+		if !now.Before(validUntil) {
+			return VerificationExpired, nil
+		}
+	}
+
+	return VerificationValid, nil
+}
+
+// setValidity records the validity window of a new certificate. Bounds are RFC 3339 and stored
+// in UTC; when only validUntil is given the window starts at registrationTime.
+func setValidity(certificate *Certificate, validFrom string, validUntil string, registrationTime time.Time) error {
+	if validFrom == "" && validUntil == "" {
+		return nil
+	}
+
+	from := registrationTime.UTC()
+	if validFrom != "" {
+		parsed, err := time.Parse(time.RFC3339, validFrom)
+		if err != nil {
+			return fmt.Errorf("validFrom %s is not an RFC 3339 timestamp", validFrom)
+		}
+		from = parsed.UTC()
+	}
+	certificate.ValidFrom = from.Format(validityTimeFormat)
+
+	if validUntil != "" {
+		until, err := time.Parse(time.RFC3339, validUntil)
+		if err != nil {
+			return fmt.Errorf("validUntil %s is not an RFC 3339 timestamp", validUntil)
+		}
+		if !until.UTC().After(from) {
+			return fmt.Errorf("validUntil %s must be after validFrom %s", validUntil, certificate.ValidFrom)
+		}
+		certificate.ValidUntil = until.UTC().Format(validityTimeFormat)
+	}
+
+	return nil
+}
+
+// RenewCertificate issues a successor to a contract certificate, e.g. for a retainer that runs
+// for another term. The successor keeps the project, contract and parties of the renewed
+// certificate and points back to it with supersedes; the renewed certificate points forward
+// with supersededBy and stays verifiable until the successor takes effect. Only the identity
+// that issued the renewed certificate, or an admin, may renew it.
+func (s *CertificateContract) RenewCertificate(ctx contractapi.TransactionContextInterface, input RenewalInput) (*Certificate, error) {
+	if input.CertificateID == "" || input.NewCertificateID == "" || input.IPFSHash == "" {
+		return nil, fmt.Errorf("certificateId, newCertificateId, and ipfsHash are required")
+	}
+
+	previous, err := s.GetCertificate(ctx, input.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+	if previous.CertificateType != model.CertificateTypeContract {
+		return nil, fmt.Errorf("certificate %s is a %s certificate; only %s certificates can be renewed", previous.CertificateID, previous.CertificateType, model.CertificateTypeContract)
+	}
+	if previous.SupersededBy != "" {
+		return nil, fmt.Errorf("certificate %s was already renewed by %s", previous.CertificateID, previous.SupersededBy)
+	}
+
+	err = requireIssuer(ctx, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	existingJSON, _, err := getCertificateState(ctx, input.NewCertificateID)
+	if err != nil {
+		return nil, err
+	}
+	if existingJSON != nil {
+		return nil, fmt.Errorf("certificate %s already exists", input.NewCertificateID)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	amount := input.Amount
+	if amount == "" {
+		amount = previous.Amount
+	}
+
+	successor := Certificate{
+		CertificateID:   input.NewCertificateID,
+		ProjectID:       previous.ProjectID,
+		ContractID:      previous.ContractID,
+		IPFSHash:        input.IPFSHash,
+		TransactionHash: input.TransactionHash,
+		FreelancerID:    previous.FreelancerID,
+		ClientID:        previous.ClientID,
+		Amount:          amount,
+		Timestamp:       txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
+		Status:          "active",
+		CertificateType: model.CertificateTypeContract,
+		IPFSGroupID:     previous.IPFSGroupID,
+		Supersedes:      previous.CertificateID,
+	}
+
+	// The new term starts where the old one ends unless told otherwise
+	validFrom := input.ValidFrom
+	if validFrom == "" {
+		validFrom = previous.ValidUntil
+	}
+	err = setValidity(&successor, validFrom, input.ValidUntil, txTimestamp.AsTime())
+	if err != nil {
+		return nil, err
+	}
+
+	// Record who issued it
+	err = setIssuer(ctx, &successor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the CID and record the document digest
+	err = setContentInfo(&successor, input.ContentSHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	successorJSON, err := json.Marshal(successor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal certificate: %v", err)
+	}
+	err = ctx.GetStub().PutState(certificateKey(successor.CertificateID), successorJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put certificate to state: %v", err)
+	}
+
+	previous.SupersededBy = successor.CertificateID
+	previousJSON, err := json.Marshal(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal certificate: %v", err)
+	}
+	err = putCertificateState(ctx, previous.CertificateID, previousJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the successor like any other certificate of the project
+	err = putCompositeIndex(ctx, "project~certificate", successor.ProjectID, successor.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+	if successor.IPFSGroupID != "" {
+		err = putCompositeIndex(ctx, "group~certificate", successor.IPFSGroupID, successor.CertificateID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = putCertificatePartyIndexes(ctx, &successor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &successor, nil
+}
@@ -0,0 +1,29 @@
+// Package qr renders signed certificate verification payloads as QR codes. It is
+// kept apart from package verification so the chaincode does not link the encoder.
+package qr
+
+import (
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+
+	"certificate-registry/verification"
+)
+
+// DefaultSize is the PNG width and height used when size is 0
+const DefaultSize = 256
+
+// PNG encodes a signed payload as a QR code PNG of size x size pixels.
+// Medium error correction leaves room for a logo or wear on printed certificates.
+func PNG(signed *verification.Signed, size int) ([]byte, error) {
+	if size == 0 {
+		size = DefaultSize
+	}
+
+	png, err := qrcode.Encode(signed.Encode(), qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %v", err)
+	}
+
+	return png, nil
+}
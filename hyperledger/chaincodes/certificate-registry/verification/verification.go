@@ -0,0 +1,136 @@
+// Package verification builds the compact, signed payload printed on certificates
+// so they can be checked by scanning a code. The chaincode returns the unsigned
+// Payload; the issuer signs it off-chain with its Ed25519 key, because a chaincode
+// cannot keep a private key. The scanning side decodes the text, checks the
+// signature against the issuer's published key and may then confirm the
+// certificate with VerifyCertificate on the ledger.
+//
+// The encoded form is "CRV1." followed by the base64url payload JSON, a dot and
+// the base64url signature over the payload segment, much like a compact JWS.
+package verification
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Prefix starts every encoded payload and names its format version
+const Prefix = "CRV1"
+
+// Payload identifies a certificate on the ledger. Keys are short to keep the code small.
+type Payload struct {
+	Channel       string `json:"ch"`
+	Chaincode     string `json:"cc"`
+	CertificateID string `json:"id"`
+	IPFSHash      string `json:"h"`
+	ContentSHA256 string `json:"sha,omitempty" metadata:",optional"`
+	Issuer        string `json:"iss,omitempty" metadata:",optional"` // MSP ID of the registering organization
+	IssuedAt      string `json:"iat,omitempty" metadata:",optional"` // registration time of the certificate
+}
+
+// Signed is a payload with the issuer's signature
+type Signed struct {
+	Payload   Payload
+	Signature []byte
+
+	encodedPayload string // payload segment the signature covers
+}
+
+// Sign signs a payload with the issuer's private key
+func Sign(payload Payload, privateKey ed25519.PrivateKey) (*Signed, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 private key length %d", len(privateKey))
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	return &Signed{
+		Payload:        payload,
+		Signature:      ed25519.Sign(privateKey, signingInput(encodedPayload)),
+		encodedPayload: encodedPayload,
+	}, nil
+}
+
+// Encode returns the text form of a signed payload, the content of the QR code
+func (s *Signed) Encode() string {
+	return Prefix + "." + s.encodedPayload + "." + base64.RawURLEncoding.EncodeToString(s.Signature)
+}
+
+// Decode parses the text form of a signed payload without checking the signature,
+// so the verifier can read the issuer and pick its key
+func Decode(text string) (*Signed, error) {
+	parts := strings.Split(strings.TrimSpace(text), ".")
+	if len(parts) != 3 || parts[0] != Prefix {
+		return nil, fmt.Errorf("not a %s certificate verification code", Prefix)
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	var payload Payload
+	err = json.Unmarshal(payloadJSON, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	if payload.CertificateID == "" || payload.IPFSHash == "" {
+		return nil, fmt.Errorf("payload is missing the certificate ID or IPFS hash")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	return &Signed{
+		Payload:        payload,
+		Signature:      signature,
+		encodedPayload: parts[1],
+	}, nil
+}
+
+// Verify checks the signature of a decoded payload against the issuer's public key
+func (s *Signed) Verify(publicKey ed25519.PublicKey) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid Ed25519 public key length %d", len(publicKey))
+	}
+	if !ed25519.Verify(publicKey, signingInput(s.encodedPayload), s.Signature) {
+		return fmt.Errorf("signature of certificate %s does not match the issuer key", s.Payload.CertificateID)
+	}
+
+	return nil
+}
+
+// DecodeAndVerify decodes a scanned code and checks it with the key of the issuer it names.
+// keys maps issuer MSP IDs to their published public keys.
+func DecodeAndVerify(text string, keys map[string]ed25519.PublicKey) (*Payload, error) {
+	signed, err := Decode(text)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := keys[signed.Payload.Issuer]
+	if !ok {
+		return nil, fmt.Errorf("no public key for issuer %q", signed.Payload.Issuer)
+	}
+
+	err = signed.Verify(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signed.Payload, nil
+}
+
+// signingInput binds the signature to the format version as well as the payload
+func signingInput(encodedPayload string) []byte {
+	return []byte(Prefix + "." + encodedPayload)
+}
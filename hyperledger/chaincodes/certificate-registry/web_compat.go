@@ -0,0 +1,136 @@
+//go:build !nowebcompat
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+
+	"certificate-registry/model"
+)
+
+// Compatibility with the certificate-registry chaincode from web/chaincode, which was
+// deployed under the same name. Its records are read through the model package; this
+// file keeps its transactions working for existing clients. Build with -tags nowebcompat
+// to drop them once no client depends on them.
+
+func init() {
+	features.webCompat = true
+
+	positionalFields["RegisterCertificate"] = [][]string{
+		{"certificateId", "projectId", "milestoneId", "ipfsHash", "transactionHash", "freelancerId", "clientId", "amount"},
+	}
+	// The web registry's RegisterProject had no ipfsGroupHash argument
+	positionalFields["RegisterProject"] = append(positionalFields["RegisterProject"],
+		[]string{"projectId", "title", "description", "category", "clientId", "totalBudget", "deadline", "skillsRequired", "ipfsHash"},
+	)
+}
+
+// CertificateInput is the argument of RegisterCertificate
+type CertificateInput struct {
+	CertificateID   string `json:"certificateId"`
+	ProjectID       string `json:"projectId"`
+	MilestoneID     string `json:"milestoneId,omitempty" metadata:",optional"`
+	IPFSHash        string `json:"ipfsHash"`
+	TransactionHash string `json:"transactionHash,omitempty" metadata:",optional"`
+	FreelancerID    string `json:"freelancerId,omitempty" metadata:",optional"`
+	ClientID        string `json:"clientId,omitempty" metadata:",optional"`
+	Amount          string `json:"amount,omitempty" metadata:",optional"`
+	ContentSHA256   string `json:"contentSha256,omitempty" metadata:",optional"` // hex SHA-256 of the certificate document
+}
+
+// RegisterCertificate creates a certificate the way the web registry did
+// The certificate is MILESTONE when milestoneId is set and CONTRACT otherwise. It has no
+// contract ID, so the payment cannot be confirmed with escrow and the certificate is
+// stored as unverified; use RegisterContractCertificate or RegisterMilestoneCertificate instead.
+//
+// Deprecated: kept for clients of the web registry.
+func (s *CertificateContract) RegisterCertificate(ctx contractapi.TransactionContextInterface, input CertificateInput) error {
+	// Validate required fields
+	if input.CertificateID == "" || input.ProjectID == "" || input.IPFSHash == "" {
+		return fmt.Errorf("certificateId, projectId, and ipfsHash are required")
+	}
+
+	// Check if certificate already exists
+	certificateJSON, _, err := getCertificateState(ctx, input.CertificateID)
+	if err != nil {
+		return err
+	}
+	if certificateJSON != nil {
+		return fmt.Errorf("certificate %s already exists", input.CertificateID)
+	}
+
+	// The web registry did not require the project to be registered
+	groupId := ""
+	projectJSON, err := ctx.GetStub().GetState("project:" + input.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to read project: %v", err)
+	}
+	if projectJSON != nil {
+		project, _, err := model.DecodeProject(projectJSON)
+		if err != nil {
+			return err
+		}
+		groupId = project.IPFSGroupID
+	}
+
+	// Get transaction timestamp
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	certificate := Certificate{
+		CertificateID:   input.CertificateID,
+		ProjectID:       input.ProjectID,
+		MilestoneID:     input.MilestoneID,
+		IPFSHash:        input.IPFSHash,
+		TransactionHash: input.TransactionHash,
+		FreelancerID:    input.FreelancerID,
+		ClientID:        input.ClientID,
+		Amount:          input.Amount,
+		Timestamp:       txTimestamp.AsTime().Format("2006-01-02T15:04:05Z"),
+		Status:          model.StatusUnverified,
+		IPFSGroupID:     groupId,
+	}
+	certificate.CertificateType = model.InferCertificateType(&certificate)
+
+	// Record who issued it
+	err = setIssuer(ctx, &certificate)
+	if err != nil {
+		return err
+	}
+
+	err = setContentInfo(&certificate, input.ContentSHA256)
+	if err != nil {
+		return err
+	}
+
+	certificateJSON, err = json.Marshal(certificate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificate: %v", err)
+	}
+
+	// Save certificate to state
+	err = ctx.GetStub().PutState(certificateKey(input.CertificateID), certificateJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put certificate to state: %v", err)
+	}
+
+	// Create composite keys for indexing
+	err = putCompositeIndex(ctx, "project~certificate", input.ProjectID, input.CertificateID)
+	if err != nil {
+		return err
+	}
+	if groupId != "" {
+		err = putCompositeIndex(ctx, "group~certificate", groupId, input.CertificateID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Index by freelancer and client for portfolio queries
+	return putCertificatePartyIndexes(ctx, &certificate)
+}
@@ -0,0 +1,56 @@
+// Package access holds the caller checks shared by the chaincodes.
+package access
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RequireAdmin fails unless the caller is an organization admin, either by the
+// "admin" node OU on its certificate or by a "role=admin" attribute
+func RequireAdmin(ctx contractapi.TransactionContextInterface) error {
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+	if err != nil {
+		return fmt.Errorf("failed to read role attribute: %v", err)
+	}
+	if found && role == "admin" {
+		return nil
+	}
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to read client certificate: %v", err)
+	}
+	if cert != nil {
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == "admin" {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("caller is not an admin")
+}
+
+// GetCallerID returns the platform user ID of the caller: the "userId" attribute on its
+// certificate, or its enrollment ID if it has none. BobCoin uses it as the caller's address.
+func GetCallerID(ctx contractapi.TransactionContextInterface) (string, error) {
+	userId, found, err := ctx.GetClientIdentity().GetAttributeValue("userId")
+	if err != nil {
+		return "", fmt.Errorf("failed to read userId attribute: %v", err)
+	}
+	if found && userId != "" {
+		return userId, nil
+	}
+
+	enrollmentId, found, err := ctx.GetClientIdentity().GetAttributeValue("hf.EnrollmentID")
+	if err != nil {
+		return "", fmt.Errorf("failed to read enrollment ID: %v", err)
+	}
+	if !found || enrollmentId == "" {
+		return "", fmt.Errorf("caller identity has no userId attribute or enrollment ID")
+	}
+
+	return enrollmentId, nil
+}
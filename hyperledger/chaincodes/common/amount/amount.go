@@ -0,0 +1,91 @@
+// Package amount parses and formats token amounts shared by the chaincodes.
+//
+// An amount has two representations. Raw amounts are non-negative integers in the
+// token's smallest unit; they are what the ledger stores and what arithmetic uses.
+// Display amounts are decimal strings in whole tokens, with at most as many
+// fractional digits as the token has decimals, e.g. "1.5" for 1500000000000000000
+// raw units of an 18-decimal token. Both forms are parsed strictly: signs, exponents,
+// whitespace, "1." and ".5" are rejected, and nothing is ever rounded or truncated.
+package amount
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParseRaw parses a raw amount, a string of decimal digits
+func ParseRaw(s string) (*big.Int, error) {
+	if !isDigits(s) {
+		return nil, fmt.Errorf("invalid raw amount %q: must be a non-negative integer", s)
+	}
+
+	raw, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid raw amount %q", s)
+	}
+
+	return raw, nil
+}
+
+// ParseDisplay parses a display amount of a token with the given number of decimals
+// and returns it in raw units
+func ParseDisplay(s string, decimals int) (*big.Int, error) {
+	if decimals < 0 {
+		return nil, fmt.Errorf("invalid number of decimals %d", decimals)
+	}
+
+	whole, fraction, hasPoint := strings.Cut(s, ".")
+	if !isDigits(whole) || (hasPoint && !isDigits(fraction)) {
+		return nil, fmt.Errorf("invalid amount %q: must be digits with an optional decimal point between them", s)
+	}
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("invalid amount %q: more than %d decimal places", s, decimals)
+	}
+
+	raw, ok := new(big.Int).SetString(whole+fraction+strings.Repeat("0", decimals-len(fraction)), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return raw, nil
+}
+
+// FormatDisplay formats a raw amount as a display amount of a token with the given
+// number of decimals. The result is canonical: no leading zeros in the whole part, no
+// trailing zeros in the fraction and no decimal point for whole amounts.
+// ParseDisplay(FormatDisplay(raw, decimals), decimals) returns raw.
+func FormatDisplay(raw *big.Int, decimals int) string {
+	digits := new(big.Int).Abs(raw).String()
+	sign := ""
+	if raw.Sign() < 0 {
+		sign = "-"
+	}
+	if decimals <= 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals+1-len(digits)) + digits
+	}
+	whole := digits[:len(digits)-decimals]
+	fraction := strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+
+	return sign + whole + "." + fraction
+}
+
+// isDigits reports whether s is a non-empty string of ASCII decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
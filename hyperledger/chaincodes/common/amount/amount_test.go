@@ -0,0 +1,161 @@
+package amount
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseDisplay(t *testing.T) {
+	tests := []struct {
+		s        string
+		decimals int
+		want     string
+	}{
+		{"0", 18, "0"},
+		{"1", 0, "1"},
+		{"1.5", 18, "1500000000000000000"},
+		{"0.000000000000000001", 18, "1"},
+		{"007.10", 2, "710"},
+		{"123456789012345678901234567890", 2, "12345678901234567890123456789000"},
+	}
+	for _, test := range tests {
+		raw, err := ParseDisplay(test.s, test.decimals)
+		if err != nil {
+			t.Errorf("ParseDisplay(%q, %d) failed: %v", test.s, test.decimals, err)
+			continue
+		}
+		if raw.String() != test.want {
+			t.Errorf("ParseDisplay(%q, %d) = %s, want %s", test.s, test.decimals, raw, test.want)
+		}
+	}
+}
+
+func TestParseDisplayRejects(t *testing.T) {
+	tests := []struct {
+		s        string
+		decimals int
+	}{
+		{"", 18},
+		{"-1", 18},
+		{"+1", 18},
+		{"-0", 18},
+		{"1.", 18},
+		{".5", 18},
+		{".", 18},
+		{"1.2.3", 18},
+		{"1.123", 2},
+		{"1.5", 0},
+		{"0.0000000000000000001", 18},
+		{" 1", 18},
+		{"1 ", 18},
+		{"1 000", 18},
+		{"\t1", 18},
+		{"1\n", 18},
+		{"1e18", 18},
+		{"1E2", 18},
+		{"1.5e-1", 18},
+		{"0x10", 18},
+		{"1_000", 18},
+		{"1,5", 18},
+		{"NaN", 18},
+		{"Inf", 18},
+		{"１", 18},
+		{"1", -1},
+	}
+	for _, test := range tests {
+		raw, err := ParseDisplay(test.s, test.decimals)
+		if err == nil {
+			t.Errorf("ParseDisplay(%q, %d) = %s, want an error", test.s, test.decimals, raw)
+		}
+	}
+}
+
+func TestParseRaw(t *testing.T) {
+	valid := []struct {
+		s    string
+		want string
+	}{
+		{"0", "0"},
+		{"000", "0"},
+		{"0042", "42"},
+		{"1500000000000000000", "1500000000000000000"},
+		{"115792089237316195423570985008687907853269984665640564039457584007913129639936", "115792089237316195423570985008687907853269984665640564039457584007913129639936"},
+	}
+	for _, test := range valid {
+		raw, err := ParseRaw(test.s)
+		if err != nil {
+			t.Errorf("ParseRaw(%q) failed: %v", test.s, err)
+			continue
+		}
+		if raw.String() != test.want {
+			t.Errorf("ParseRaw(%q) = %s, want %s", test.s, raw, test.want)
+		}
+	}
+
+	invalid := []string{"", "-1", "+1", "1.0", "1.", ".5", "1e18", " 1", "1 ", "0x10", "1_000", "abc", "１"}
+	for _, s := range invalid {
+		raw, err := ParseRaw(s)
+		if err == nil {
+			t.Errorf("ParseRaw(%q) = %s, want an error", s, raw)
+		}
+	}
+}
+
+func TestFormatDisplay(t *testing.T) {
+	tests := []struct {
+		raw      string
+		decimals int
+		want     string
+	}{
+		{"0", 18, "0"},
+		{"1", 18, "0.000000000000000001"},
+		{"1500000000000000000", 18, "1.5"},
+		{"1000000000000000000", 18, "1"},
+		{"710", 2, "7.1"},
+		{"42", 0, "42"},
+		{"-150", 2, "-1.5"},
+	}
+	for _, test := range tests {
+		raw, _ := new(big.Int).SetString(test.raw, 10)
+		got := FormatDisplay(raw, test.decimals)
+		if got != test.want {
+			t.Errorf("FormatDisplay(%s, %d) = %q, want %q", test.raw, test.decimals, got, test.want)
+		}
+	}
+}
+
+func FuzzParseDisplay(f *testing.F) {
+	f.Add("0", 18)
+	f.Add("1.5", 18)
+	f.Add("007.10", 2)
+	f.Add("1.", 18)
+	f.Add(".5", 18)
+	f.Add("1e18", 0)
+	f.Add("-1", 6)
+
+	f.Fuzz(func(t *testing.T, s string, decimals int) {
+		if decimals < 0 || decimals > 36 {
+			t.Skip()
+		}
+
+		raw, err := ParseDisplay(s, decimals)
+		if err != nil {
+			return
+		}
+		if raw.Sign() < 0 {
+			t.Fatalf("ParseDisplay(%q, %d) = %s, which is negative", s, decimals, raw)
+		}
+
+		formatted := FormatDisplay(raw, decimals)
+		again, err := ParseDisplay(formatted, decimals)
+		if err != nil {
+			t.Fatalf("ParseDisplay(%q, %d) rejected FormatDisplay output of %q: %v", formatted, decimals, s, err)
+		}
+		if again.Cmp(raw) != 0 {
+			t.Fatalf("%q parsed to %s but its formatted form %q parsed to %s", s, raw, formatted, again)
+		}
+		if FormatDisplay(again, decimals) != formatted {
+			t.Fatalf("FormatDisplay is not canonical for %q: %q then %q", s, formatted, FormatDisplay(again, decimals))
+		}
+	})
+}
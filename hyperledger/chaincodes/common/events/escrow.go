@@ -0,0 +1,42 @@
+package events
+
+// Event types emitted by escrow
+const (
+	TypeContractCreated   = "ContractCreated"
+	TypeFundsLocked       = "FundsLocked"
+	TypeMilestoneReleased = "MilestoneReleased"
+	TypeProjectRefunded   = "ProjectRefunded"
+)
+
+// ContractCreated is emitted when an escrow contract is created for a project
+type ContractCreated struct {
+	Envelope
+	ContractID string `json:"contractId"`
+	ProjectID  string `json:"projectId"`
+}
+
+// FundsLocked is emitted when funds are locked in an escrow contract
+type FundsLocked struct {
+	Envelope
+	ContractID string `json:"contractId"`
+	Amount     string `json:"amount"`
+}
+
+// MilestoneReleased is emitted when the payment of a milestone is released
+type MilestoneReleased struct {
+	Envelope
+	ContractID  string `json:"contractId"`
+	MilestoneID string `json:"milestoneId"`
+}
+
+// ProjectRefunded is emitted when an escrow contract is refunded to the client
+type ProjectRefunded struct {
+	Envelope
+	ContractID string `json:"contractId"`
+	Amount     string `json:"amount"`
+}
+
+func (*ContractCreated) EventType() string   { return TypeContractCreated }
+func (*FundsLocked) EventType() string       { return TypeFundsLocked }
+func (*MilestoneReleased) EventType() string { return TypeMilestoneReleased }
+func (*ProjectRefunded) EventType() string   { return TypeProjectRefunded }
@@ -0,0 +1,127 @@
+// Package events defines the payloads of the chaincode events emitted by the chaincodes
+// and decodes them for consumers such as block listeners and indexers.
+//
+// Every payload is a flat JSON object. The fields of Envelope come first and are the
+// same in every event: the event type, which is also the Fabric event name, the payload
+// version, and the ID and timestamp of the transaction that emitted it. The fields
+// specific to the event follow. Payloads emitted before versioning was introduced have
+// no version field and decode as version 0; their other fields are unchanged.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Version is the payload version the chaincodes emit. It goes up when a field is
+// removed or changes meaning; adding a field does not change it.
+const Version = 1
+
+// Envelope holds the fields common to every event
+type Envelope struct {
+	Type      string `json:"type"`
+	Version   int    `json:"version"`
+	TxID      string `json:"txId"`
+	Timestamp string `json:"timestamp"` // transaction timestamp, RFC 3339 in UTC
+}
+
+// Event is the payload of a chaincode event. It is implemented by the event types of
+// this package only.
+type Event interface {
+	// EventType returns the event type, which is also the Fabric event name
+	EventType() string
+	// Header returns the common fields of the event
+	Header() Envelope
+	envelope() *Envelope
+}
+
+// Header returns the common fields of an event
+func (e *Envelope) Header() Envelope {
+	return *e
+}
+
+func (e *Envelope) envelope() *Envelope {
+	return e
+}
+
+// Emit fills in the common fields of an event from the transaction and sets it as the
+// transaction's event. Fabric keeps only the last event set by a transaction, so a
+// transaction must emit at most one.
+func Emit(ctx contractapi.TransactionContextInterface, event Event) error {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	*event.envelope() = Envelope{
+		Type:      event.EventType(),
+		Version:   Version,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: txTimestamp.AsTime().UTC().Format(time.RFC3339),
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", event.EventType(), err)
+	}
+
+	err = ctx.GetStub().SetEvent(event.EventType(), payload)
+	if err != nil {
+		return fmt.Errorf("failed to set %s event: %v", event.EventType(), err)
+	}
+
+	return nil
+}
+
+// Decode parses an event payload into the event type named by its type field, e.g. a
+// *Transfer. Use a type switch on the result. Unknown types and versions newer than
+// Version are rejected, so a consumer built against an older release of this package
+// fails loudly rather than misreading a payload.
+func Decode(payload []byte) (Event, error) {
+	var header Envelope
+	err := json.Unmarshal(payload, &header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %v", err)
+	}
+	if header.Version > Version {
+		return nil, fmt.Errorf("%s event has version %d; this package decodes up to version %d", header.Type, header.Version, Version)
+	}
+
+	newEvent, found := eventTypes[header.Type]
+	if !found {
+		return nil, fmt.Errorf("unknown event type %q", header.Type)
+	}
+
+	event := newEvent()
+	err = json.Unmarshal(payload, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s event: %v", header.Type, err)
+	}
+
+	return event, nil
+}
+
+// eventTypes maps each event type to a constructor of its payload
+var eventTypes = map[string]func() Event{
+	TypeMint:                    func() Event { return &Mint{} },
+	TypeBurn:                    func() Event { return &Burn{} },
+	TypeTransfer:                func() Event { return &Transfer{} },
+	TypeBatchTransfer:           func() Event { return &BatchTransfer{} },
+	TypeConfidentialModeEnabled: func() Event { return &ConfidentialModeEnabled{} },
+	TypeConfidentialTransfer:    func() Event { return &ConfidentialTransfer{} },
+	TypeTokenMetadataUpdated:    func() Event { return &TokenMetadataUpdated{} },
+	TypeMintAllowanceSet:        func() Event { return &MintAllowanceSet{} },
+	TypeMintAllowanceRemoved:    func() Event { return &MintAllowanceRemoved{} },
+	TypeSupplyQuotaSet:          func() Event { return &SupplyQuotaSet{} },
+	TypePaused:                  func() Event { return &Paused{} },
+	TypeUnpaused:                func() Event { return &Unpaused{} },
+	TypeAccountFrozen:           func() Event { return &AccountFrozen{} },
+	TypeAccountUnfrozen:         func() Event { return &AccountUnfrozen{} },
+	TypeContractCreated:         func() Event { return &ContractCreated{} },
+	TypeFundsLocked:             func() Event { return &FundsLocked{} },
+	TypeMilestoneReleased:       func() Event { return &MilestoneReleased{} },
+	TypeProjectRefunded:         func() Event { return &ProjectRefunded{} },
+}
@@ -0,0 +1,139 @@
+package events
+
+// Event types emitted by bobcoin. Amounts are display amounts in whole tokens.
+const (
+	TypeMint                    = "Mint"
+	TypeBurn                    = "Burn"
+	TypeTransfer                = "Transfer"
+	TypeBatchTransfer           = "BatchTransfer"
+	TypeConfidentialModeEnabled = "ConfidentialModeEnabled"
+	TypeConfidentialTransfer    = "ConfidentialTransfer"
+	TypeTokenMetadataUpdated    = "TokenMetadataUpdated"
+	TypeMintAllowanceSet        = "MintAllowanceSet"
+	TypeMintAllowanceRemoved    = "MintAllowanceRemoved"
+	TypeSupplyQuotaSet          = "SupplyQuotaSet"
+	TypePaused                  = "Paused"
+	TypeUnpaused                = "Unpaused"
+	TypeAccountFrozen           = "AccountFrozen"
+	TypeAccountUnfrozen         = "AccountUnfrozen"
+)
+
+// Mint is emitted when tokens are minted to an address
+type Mint struct {
+	Envelope
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+// Burn is emitted when tokens are burned from an address
+type Burn struct {
+	Envelope
+	From   string `json:"from"`
+	Amount string `json:"amount"`
+}
+
+// Transfer is emitted by Transfer and TransferWithMemo. Reference and Memo are only
+// set by TransferWithMemo.
+type Transfer struct {
+	Envelope
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Amount    string `json:"amount"`
+	Reference string `json:"reference,omitempty"`
+	Memo      string `json:"memo,omitempty"`
+}
+
+// BatchTransferEntry is the total paid to one recipient of a batch
+type BatchTransferEntry struct {
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+}
+
+// BatchTransfer is emitted by BatchTransfer in place of one Transfer per recipient
+type BatchTransfer struct {
+	Envelope
+	From      string                `json:"from"`
+	Total     string                `json:"total"`
+	Transfers []*BatchTransferEntry `json:"transfers"` // one per recipient, in order of first appearance
+}
+
+// ConfidentialModeEnabled is emitted when balances move into private data collections
+type ConfidentialModeEnabled struct {
+	Envelope
+}
+
+// ConfidentialTransfer is emitted by ConfidentialTransfer. It names the parties but not
+// the amount.
+type ConfidentialTransfer struct {
+	Envelope
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TokenMetadataUpdated is emitted when the token name or symbol changes
+type TokenMetadataUpdated struct {
+	Envelope
+	Name   string `json:"name"`
+	Symbol string `json:"symbol"`
+}
+
+// MintAllowanceSet is emitted when a minter's daily allowance is set
+type MintAllowanceSet struct {
+	Envelope
+	MinterID       string `json:"minterId"`
+	DailyAllowance string `json:"dailyAllowance"`
+}
+
+// MintAllowanceRemoved is emitted when a minter's daily allowance is removed
+type MintAllowanceRemoved struct {
+	Envelope
+	MinterID string `json:"minterId"`
+}
+
+// SupplyQuotaSet is emitted when a minter's share of the max supply is set
+type SupplyQuotaSet struct {
+	Envelope
+	MinterID string `json:"minterId"`
+	Quota    string `json:"quota"`
+}
+
+// Paused is emitted when token movement is halted
+type Paused struct {
+	Envelope
+	ReasonCode string `json:"reasonCode"`
+	Note       string `json:"note"`
+}
+
+// Unpaused is emitted when tokens may move again
+type Unpaused struct {
+	Envelope
+}
+
+// AccountFrozen is emitted when an address is frozen
+type AccountFrozen struct {
+	Envelope
+	Address    string `json:"address"`
+	ReasonCode string `json:"reasonCode"`
+	Note       string `json:"note"`
+}
+
+// AccountUnfrozen is emitted when a frozen address is released
+type AccountUnfrozen struct {
+	Envelope
+	Address string `json:"address"`
+}
+
+func (*Mint) EventType() string                    { return TypeMint }
+func (*Burn) EventType() string                    { return TypeBurn }
+func (*Transfer) EventType() string                { return TypeTransfer }
+func (*BatchTransfer) EventType() string           { return TypeBatchTransfer }
+func (*ConfidentialModeEnabled) EventType() string { return TypeConfidentialModeEnabled }
+func (*ConfidentialTransfer) EventType() string    { return TypeConfidentialTransfer }
+func (*TokenMetadataUpdated) EventType() string    { return TypeTokenMetadataUpdated }
+func (*MintAllowanceSet) EventType() string        { return TypeMintAllowanceSet }
+func (*MintAllowanceRemoved) EventType() string    { return TypeMintAllowanceRemoved }
+func (*SupplyQuotaSet) EventType() string          { return TypeSupplyQuotaSet }
+func (*Paused) EventType() string                  { return TypePaused }
+func (*Unpaused) EventType() string                { return TypeUnpaused }
+func (*AccountFrozen) EventType() string           { return TypeAccountFrozen }
+func (*AccountUnfrozen) EventType() string         { return TypeAccountUnfrozen }
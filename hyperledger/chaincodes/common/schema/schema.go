@@ -0,0 +1,179 @@
+// Package schema records which version of its data layout a chaincode has written
+// and upgrades the world state from one version to the next.
+//
+// Each chaincode keeps its version under its own key in its own namespace. Version 0
+// means the ledger is empty and has not been initialized; version 1 is the layout
+// the chaincode wrote before versioning was introduced.
+package schema
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Step upgrades the world state to Version from the version before it
+type Step struct {
+	Version     int
+	Description string
+	Apply       func(ctx contractapi.TransactionContextInterface) error
+}
+
+// UpgradeResult reports what one Upgrade call did
+type UpgradeResult struct {
+	FromVersion    int    `json:"fromVersion"`
+	ToVersion      int    `json:"toVersion"`
+	CurrentVersion int    `json:"currentVersion"` // version the chaincode writes
+	Description    string `json:"description"`    // step applied, empty if none
+	Done           bool   `json:"done"`           // true once the ledger is at the current version
+}
+
+// Registry tracks the schema version of one chaincode's world state and the
+// steps that upgrade it
+type Registry struct {
+	key     string
+	current int
+	steps   map[int]Step
+
+	// HasLegacyState reports whether the ledger holds records written before
+	// versioning was introduced. Such a ledger is treated as version 1. When nil,
+	// an unversioned ledger is treated as empty.
+	HasLegacyState func(ctx contractapi.TransactionContextInterface) (bool, error)
+}
+
+// NewRegistry returns a registry that stores the version under key. current is the
+// version the chaincode writes; there must be one step for each version from 2 to current.
+func NewRegistry(key string, current int, steps ...Step) *Registry {
+	registry := &Registry{key: key, current: current, steps: map[int]Step{}}
+	for _, step := range steps {
+		if step.Version < 2 || step.Version > current {
+			panic(fmt.Sprintf("schema step %d is outside versions 2 to %d", step.Version, current))
+		}
+		if _, exists := registry.steps[step.Version]; exists {
+			panic(fmt.Sprintf("schema step %d registered twice", step.Version))
+		}
+		registry.steps[step.Version] = step
+	}
+
+	for version := 2; version <= current; version++ {
+		if _, exists := registry.steps[version]; !exists {
+			panic(fmt.Sprintf("no schema step upgrades to version %d", version))
+		}
+	}
+
+	return registry
+}
+
+// Key returns the state key the version is stored under
+func (r *Registry) Key() string {
+	return r.key
+}
+
+// Current returns the version the chaincode writes
+func (r *Registry) Current() int {
+	return r.current
+}
+
+// Version returns the version of the world state
+func (r *Registry) Version(ctx contractapi.TransactionContextInterface) (int, error) {
+	versionBytes, err := ctx.GetStub().GetState(r.key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	if versionBytes != nil {
+		version, err := strconv.Atoi(string(versionBytes))
+		if err != nil {
+			return 0, fmt.Errorf("invalid schema version %q: %v", versionBytes, err)
+		}
+		return version, nil
+	}
+
+	if r.HasLegacyState != nil {
+		legacy, err := r.HasLegacyState(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if legacy {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// SetVersion records the version of the world state
+func (r *Registry) SetVersion(ctx contractapi.TransactionContextInterface, version int) error {
+	err := ctx.GetStub().PutState(r.key, []byte(strconv.Itoa(version)))
+	if err != nil {
+		return fmt.Errorf("failed to record schema version: %v", err)
+	}
+
+	return nil
+}
+
+// Init runs setup on an uninitialized ledger and records the current version.
+// It returns false without calling setup if the ledger already has a version or
+// holds legacy state, so calling it again never overwrites existing data.
+func (r *Registry) Init(ctx contractapi.TransactionContextInterface, setup func() error) (bool, error) {
+	version, err := r.Version(ctx)
+	if err != nil {
+		return false, err
+	}
+	if version != 0 {
+		return false, nil
+	}
+
+	if setup != nil {
+		err = setup()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = r.SetVersion(ctx, r.current)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Upgrade applies the next step towards the current version and records the version it
+// reached. Only one step runs per transaction, because a transaction does not see its own
+// writes and a step may depend on the records the previous one rewrote; call it until the
+// result is done.
+func (r *Registry) Upgrade(ctx contractapi.TransactionContextInterface) (*UpgradeResult, error) {
+	version, err := r.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpgradeResult{FromVersion: version, ToVersion: version, CurrentVersion: r.current}
+	switch {
+	case version == 0:
+		return nil, fmt.Errorf("ledger has not been initialized; call InitLedger first")
+	case version > r.current:
+		return nil, fmt.Errorf("ledger is at schema version %d, newer than version %d of this chaincode", version, r.current)
+	case version == r.current:
+		result.Done = true
+		return result, nil
+	}
+
+	step := r.steps[version+1]
+	err = step.Apply(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade schema to version %d: %v", step.Version, err)
+	}
+
+	err = r.SetVersion(ctx, step.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	result.ToVersion = step.Version
+	result.Description = step.Description
+	result.Done = step.Version == r.current
+	return result, nil
+}
@@ -22,6 +22,12 @@ BOBCOIN_CC="bobcoin"
 ESCROW_CC="escrow"
 CERTIFICATE_CC="certificate-registry"
 
+# BobCoin token metadata passed to InitLedger (max supply in whole tokens; empty for unlimited)
+BOBCOIN_NAME="${BOBCOIN_NAME:-BobCoin}"
+BOBCOIN_SYMBOL="${BOBCOIN_SYMBOL:-BOB}"
+BOBCOIN_DECIMALS="${BOBCOIN_DECIMALS:-18}"
+BOBCOIN_MAX_SUPPLY="${BOBCOIN_MAX_SUPPLY:-}"
+
 # Paths - Get absolute paths
 SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
 FABRIC_ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
@@ -74,6 +80,13 @@ package_chaincode() {
         echo -e "${RED}Failed to download dependencies for $CC_NAME${NC}"
         exit 1
     fi
+
+    # Vendor dependencies so the shared chaincode-common module is packaged with the chaincode
+    go mod vendor
+    if [ $? -ne 0 ]; then
+        echo -e "${RED}Failed to vendor dependencies for $CC_NAME${NC}"
+        exit 1
+    fi
     
     # Ensure we're in the network directory for packaging
     cd "$FABRIC_NETWORK_DIR"
@@ -132,6 +145,12 @@ approve_chaincode() {
     local CC_NAME=$1
     local PACKAGE_ID=$2
     local ORG=$3
+    local COLLECTIONS_CONFIG=$4  # optional private data collections definition
+    
+    local COLLECTIONS_ARGS=()
+    if [ -n "$COLLECTIONS_CONFIG" ]; then
+        COLLECTIONS_ARGS=(--collections-config "$COLLECTIONS_CONFIG")
+    fi
     
     echo -e "${BLUE}Approving $CC_NAME for $ORG...${NC}"
     
@@ -156,6 +175,7 @@ approve_chaincode() {
         --version $CHAINCODE_VERSION \
         --package-id $PACKAGE_ID \
         --sequence $SEQUENCE \
+        "${COLLECTIONS_ARGS[@]}" \
         --tls \
         --cafile ${PWD}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem
     
@@ -170,6 +190,12 @@ approve_chaincode() {
 # Function to commit chaincode
 commit_chaincode() {
     local CC_NAME=$1
+    local COLLECTIONS_CONFIG=$2  # must match the one approved
+    
+    local COLLECTIONS_ARGS=()
+    if [ -n "$COLLECTIONS_CONFIG" ]; then
+        COLLECTIONS_ARGS=(--collections-config "$COLLECTIONS_CONFIG")
+    fi
     
     echo -e "${BLUE}Committing $CC_NAME to channel...${NC}"
     
@@ -186,6 +212,7 @@ commit_chaincode() {
         --name $CC_NAME \
         --version $CHAINCODE_VERSION \
         --sequence $SEQUENCE \
+        "${COLLECTIONS_ARGS[@]}" \
         --tls \
         --cafile ${PWD}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem \
         --peerAddresses localhost:7051 \
@@ -218,7 +245,7 @@ approve_chaincode $BOBCOIN_CC $BOBCOIN_PACKAGE_ID "Org2"
 commit_chaincode $BOBCOIN_CC
 
 # Initialize BobCoin
-echo -e "${BLUE}Initializing BobCoin contract...${NC}"
+echo -e "${BLUE}Initializing BobCoin contract ($BOBCOIN_NAME, $BOBCOIN_SYMBOL, $BOBCOIN_DECIMALS decimals)...${NC}"
 export CORE_PEER_TLS_ENABLED=true
 export CORE_PEER_LOCALMSPID="Org1MSP"
 export CORE_PEER_TLS_ROOTCERT_FILE=${PWD}/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt
@@ -236,7 +263,7 @@ peer chaincode invoke \
     --tlsRootCertFiles ${PWD}/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt \
     --peerAddresses localhost:9051 \
     --tlsRootCertFiles ${PWD}/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt \
-    -c '{"function":"InitLedger","Args":[]}'
+    -c '{"function":"InitLedger","Args":["'"$BOBCOIN_NAME"'","'"$BOBCOIN_SYMBOL"'","'"$BOBCOIN_DECIMALS"'","'"$BOBCOIN_MAX_SUPPLY"'"]}'
 
 echo -e "${GREEN}✓ BobCoin initialized${NC}"
 
@@ -285,9 +312,11 @@ install_chaincode $CERTIFICATE_CC "Org2"
 CERTIFICATE_PACKAGE_ID=$(get_package_id $CERTIFICATE_CC)
 echo -e "${BLUE}Certificate Registry Package ID: $CERTIFICATE_PACKAGE_ID${NC}"
 
-approve_chaincode $CERTIFICATE_CC $CERTIFICATE_PACKAGE_ID "Org1"
-approve_chaincode $CERTIFICATE_CC $CERTIFICATE_PACKAGE_ID "Org2"
-commit_chaincode $CERTIFICATE_CC
+# Project details are kept in a private data collection
+CERTIFICATE_COLLECTIONS="$CHAINCODES_DIR/certificate-registry/collections_config.json"
+approve_chaincode $CERTIFICATE_CC $CERTIFICATE_PACKAGE_ID "Org1" "$CERTIFICATE_COLLECTIONS"
+approve_chaincode $CERTIFICATE_CC $CERTIFICATE_PACKAGE_ID "Org2" "$CERTIFICATE_COLLECTIONS"
+commit_chaincode $CERTIFICATE_CC "$CERTIFICATE_COLLECTIONS"
 
 # Initialize Certificate Registry
 echo -e "${BLUE}Initializing Certificate Registry contract...${NC}"
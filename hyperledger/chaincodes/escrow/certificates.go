@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// certificateChaincodeName is the name the certificate-registry chaincode is deployed under on the same channel
+const certificateChaincodeName = "certificate-registry"
+
+// Transient map keys read when a certificate is issued automatically
+const (
+	transientCertificateID     = "certificateId"       // optional, defaults to a deterministic ID
+	transientCertificateIPFS   = "certificateIpfsHash" // required, IPFS hash of the certificate document
+	transientCertificateSHA256 = "certificateSha256"   // optional, hex SHA-256 of the certificate document
+)
+
+// contractCertificateInput is the JSON input of certificate-registry's RegisterContractCertificate
+type contractCertificateInput struct {
+	CertificateID   string `json:"certificateId"`
+	ProjectID       string `json:"projectId"`
+	ContractID      string `json:"contractId"`
+	IPFSHash        string `json:"ipfsHash"`
+	TransactionHash string `json:"transactionHash"`
+	FreelancerID    string `json:"freelancerId"`
+	ClientID        string `json:"clientId"`
+	Amount          string `json:"amount"`
+	ContentSHA256   string `json:"contentSha256,omitempty"`
+}
+
+// milestoneCertificateInput is the JSON input of certificate-registry's RegisterMilestoneCertificate
+type milestoneCertificateInput struct {
+	CertificateID   string `json:"certificateId"`
+	ProjectID       string `json:"projectId"`
+	ContractID      string `json:"contractId"`
+	MilestoneID     string `json:"milestoneId"`
+	IPFSHash        string `json:"ipfsHash"`
+	TransactionHash string `json:"transactionHash"`
+	FreelancerID    string `json:"freelancerId"`
+	ClientID        string `json:"clientId"`
+	Amount          string `json:"amount"`
+	ContentSHA256   string `json:"contentSha256,omitempty"`
+}
+
+// issueContractCertificate registers the CONTRACT certificate for a contract in certificate-registry
+func issueContractCertificate(ctx contractapi.TransactionContextInterface, contract *EscrowContractData) error {
+	details, err := getCertificateDetails(ctx, contract.ContractID+"-contract")
+	if err != nil {
+		return err
+	}
+
+	return invokeCertificateRegistry(ctx, "RegisterContractCertificate", contractCertificateInput{
+		CertificateID:   details.certificateID,
+		ProjectID:       contract.ProjectID,
+		ContractID:      contract.ContractID,
+		IPFSHash:        details.ipfsHash,
+		TransactionHash: ctx.GetStub().GetTxID(),
+		FreelancerID:    contract.FreelancerAddress,
+		ClientID:        contract.ClientAddress,
+		Amount:          contract.TotalAmount,
+		ContentSHA256:   details.contentSHA256,
+	})
+}
+
+// issueMilestoneCertificate registers the MILESTONE certificate for a released milestone in certificate-registry
+func issueMilestoneCertificate(ctx contractapi.TransactionContextInterface, contract *EscrowContractData, milestone *Milestone) error {
+	details, err := getCertificateDetails(ctx, contract.ContractID+"-"+milestone.MilestoneID)
+	if err != nil {
+		return err
+	}
+
+	return invokeCertificateRegistry(ctx, "RegisterMilestoneCertificate", milestoneCertificateInput{
+		CertificateID:   details.certificateID,
+		ProjectID:       contract.ProjectID,
+		ContractID:      contract.ContractID,
+		MilestoneID:     milestone.MilestoneID,
+		IPFSHash:        details.ipfsHash,
+		TransactionHash: milestone.ReleaseTxID,
+		FreelancerID:    contract.FreelancerAddress,
+		ClientID:        contract.ClientAddress,
+		Amount:          milestone.Amount,
+		ContentSHA256:   details.contentSHA256,
+	})
+}
+
+// certificateDetails is what the caller supplies for an automatically issued certificate
+type certificateDetails struct {
+	certificateID string
+	ipfsHash      string
+	contentSHA256 string
+}
+
+// getCertificateDetails reads the certificate ID, document hash and digest from the transient map
+func getCertificateDetails(ctx contractapi.TransactionContextInterface, defaultID string) (*certificateDetails, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	ipfsHash := string(transientMap[transientCertificateIPFS])
+	if ipfsHash == "" {
+		return nil, fmt.Errorf("automatic certificates are enabled; transient field %s is required", transientCertificateIPFS)
+	}
+
+	certificateID := string(transientMap[transientCertificateID])
+	if certificateID == "" {
+		certificateID = defaultID
+	}
+
+	return &certificateDetails{
+		certificateID: certificateID,
+		ipfsHash:      ipfsHash,
+		contentSHA256: string(transientMap[transientCertificateSHA256]),
+	}, nil
+}
+
+// invokeCertificateRegistry calls certificate-registry in the current transaction so its writes commit atomically with ours
+func invokeCertificateRegistry(ctx contractapi.TransactionContextInterface, function string, input interface{}) error {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificate input: %v", err)
+	}
+
+	invokeArgs := [][]byte{[]byte(function), inputJSON}
+	response := ctx.GetStub().InvokeChaincode(certificateChaincodeName, invokeArgs, "")
+	if response.Status != shim.OK {
+		return fmt.Errorf("failed to issue certificate with %s: %s", function, response.Message)
+	}
+
+	return nil
+}
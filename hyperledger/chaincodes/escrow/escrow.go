@@ -9,6 +9,9 @@ import (
 	"fmt"
 	"time"
 
+	"chaincode-common/access"
+	"chaincode-common/events"
+	"chaincode-common/schema"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -27,6 +30,7 @@ type EscrowContractData struct {
 	LockedAmount    string   `json:"lockedAmount"`
 	Status          string   `json:"status"` // CREATED, FUNDED, IN_PROGRESS, COMPLETED, REFUNDED
 	Milestones      []Milestone `json:"milestones"`
+	AutoCertificates bool    `json:"autoCertificates,omitempty" metadata:",optional"` // issue certificates in certificate-registry automatically
 	CreatedAt       string   `json:"createdAt"`
 	UpdatedAt       string   `json:"updatedAt"`
 }
@@ -37,12 +41,34 @@ type Milestone struct {
 	Description    string `json:"description"`
 	Amount         string `json:"amount"`
 	Status         string `json:"status"` // PENDING, RELEASED, REFUNDED
-	ReleasedAt     string `json:"releasedAt,omitempty"`
+	ReleasedAt     string `json:"releasedAt,omitempty" metadata:",optional"`
+	ReleaseTxID    string `json:"releaseTxId,omitempty" metadata:",optional"` // ID of the ReleaseMilestone transaction
 }
 
+// schemaRegistry tracks the version of the escrow record layout. Version 1 is the
+// layout written before versioning; add a schema.Step here when it changes.
+var schemaRegistry = schema.NewRegistry("schema:version", 1)
+
 // InitLedger initializes the escrow contract
+// It records the schema version once and does nothing when called again
 func (s *EscrowContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	return nil
+	_, err := schemaRegistry.Init(ctx, nil)
+	return err
+}
+
+// UpgradeSchema applies the next schema upgrade step after a chaincode upgrade; call it until done is true
+func (s *EscrowContract) UpgradeSchema(ctx contractapi.TransactionContextInterface) (*schema.UpgradeResult, error) {
+	err := access.RequireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaRegistry.Upgrade(ctx)
+}
+
+// GetSchemaVersion returns the schema version recorded on the ledger, or 0 before InitLedger
+func (s *EscrowContract) GetSchemaVersion(ctx contractapi.TransactionContextInterface) (int, error) {
+	return schemaRegistry.Version(ctx)
 }
 
 // CreateContract creates a new escrow contract
@@ -102,10 +128,7 @@ func (s *EscrowContract) CreateContract(ctx contractapi.TransactionContextInterf
 	}
 
 	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"ContractCreated","contractId":"%s","projectId":"%s"}`, contractID, projectID)
-	ctx.GetStub().SetEvent("ContractCreated", []byte(eventPayload))
-
-	return nil
+	return events.Emit(ctx, &events.ContractCreated{ContractID: contractID, ProjectID: projectID})
 }
 
 // LockFunds locks funds for the escrow contract
@@ -120,6 +143,9 @@ func (s *EscrowContract) LockFunds(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("contract must be in CREATED or FUNDED status to lock funds")
 	}
 
+	// The first lock confirms the agreement, so the contract certificate is issued here
+	firstLock := contract.Status == "CREATED"
+
 	// Update locked amount
 	contract.LockedAmount = amount
 	contract.Status = "FUNDED"
@@ -135,11 +161,15 @@ func (s *EscrowContract) LockFunds(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("failed to update contract: %v", err)
 	}
 
-	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"FundsLocked","contractId":"%s","amount":"%s"}`, contractID, amount)
-	ctx.GetStub().SetEvent("FundsLocked", []byte(eventPayload))
+	if firstLock && contract.AutoCertificates {
+		err = issueContractCertificate(ctx, contract)
+		if err != nil {
+			return err
+		}
+	}
 
-	return nil
+	// Emit event
+	return events.Emit(ctx, &events.FundsLocked{ContractID: contractID, Amount: amount})
 }
 
 // ReleaseMilestone releases payment for a specific milestone
@@ -154,7 +184,7 @@ func (s *EscrowContract) ReleaseMilestone(ctx contractapi.TransactionContextInte
 	}
 
 	// Find milestone
-	milestoneFound := false
+	var releasedMilestone *Milestone
 	for i := range contract.Milestones {
 		if contract.Milestones[i].MilestoneID == milestoneID {
 			if contract.Milestones[i].Status != "PENDING" {
@@ -163,12 +193,13 @@ func (s *EscrowContract) ReleaseMilestone(ctx contractapi.TransactionContextInte
 			
 			contract.Milestones[i].Status = "RELEASED"
 			contract.Milestones[i].ReleasedAt = time.Now().UTC().Format(time.RFC3339)
-			milestoneFound = true
+			contract.Milestones[i].ReleaseTxID = ctx.GetStub().GetTxID()
+			releasedMilestone = &contract.Milestones[i]
 			break
 		}
 	}
 
-	if !milestoneFound {
+	if releasedMilestone == nil {
 		return fmt.Errorf("milestone %s not found", milestoneID)
 	}
 
@@ -199,11 +230,15 @@ func (s *EscrowContract) ReleaseMilestone(ctx contractapi.TransactionContextInte
 		return fmt.Errorf("failed to update contract: %v", err)
 	}
 
-	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"MilestoneReleased","contractId":"%s","milestoneId":"%s"}`, contractID, milestoneID)
-	ctx.GetStub().SetEvent("MilestoneReleased", []byte(eventPayload))
+	if contract.AutoCertificates {
+		err = issueMilestoneCertificate(ctx, contract, releasedMilestone)
+		if err != nil {
+			return err
+		}
+	}
 
-	return nil
+	// Emit event
+	return events.Emit(ctx, &events.MilestoneReleased{ContractID: contractID, MilestoneID: milestoneID})
 }
 
 // RefundProject refunds the entire project to the client
@@ -242,10 +277,45 @@ func (s *EscrowContract) RefundProject(ctx contractapi.TransactionContextInterfa
 	}
 
 	// Emit event
-	eventPayload := fmt.Sprintf(`{"type":"ProjectRefunded","contractId":"%s","amount":"%s"}`, contractID, contract.LockedAmount)
-	ctx.GetStub().SetEvent("ProjectRefunded", []byte(eventPayload))
+	return events.Emit(ctx, &events.ProjectRefunded{ContractID: contractID, Amount: contract.LockedAmount})
+}
+
+// SetAutoCertificates turns automatic certificate issuing on or off for a contract
+// When enabled, the first LockFunds issues the CONTRACT certificate and every ReleaseMilestone
+// issues a MILESTONE certificate in certificate-registry within the same transaction
+// Only the contract's client or freelancer may change it
+func (s *EscrowContract) SetAutoCertificates(ctx contractapi.TransactionContextInterface, contractID string, enabled bool) error {
+	contract, err := s.GetContract(ctx, contractID)
+	if err != nil {
+		return err
+	}
+
+	callerID, err := access.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	if callerID != contract.ClientAddress && callerID != contract.FreelancerAddress {
+		return fmt.Errorf("only the client or freelancer of contract %s can change its certificate settings", contractID)
+	}
+
+	if contract.Status == "COMPLETED" || contract.Status == "REFUNDED" {
+		return fmt.Errorf("cannot change certificate settings of a %s contract", contract.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	contract.AutoCertificates = enabled
+	contract.UpdatedAt = txTimestamp.AsTime().UTC().Format(time.RFC3339)
+
+	contractJSON, err := json.Marshal(contract)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contract: %v", err)
+	}
 
-	return nil
+	return ctx.GetStub().PutState(contractID, contractJSON)
 }
 
 // GetContract returns the escrow contract details
@@ -275,7 +345,7 @@ func (s *EscrowContract) GetContractsByProject(ctx contractapi.TransactionContex
 	}
 	defer resultsIterator.Close()
 
-	var contracts []*EscrowContractData
+	contracts := []*EscrowContractData{}
 	for resultsIterator.HasNext() {
 		responseRange, err := resultsIterator.Next()
 		if err != nil {
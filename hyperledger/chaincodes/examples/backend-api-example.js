@@ -241,7 +241,8 @@ app.post('/api/certificate/verify', async (req, res) => {
     const { certificateId, ipfsHash } = req.body;
     const contract = getContract(CHAINCODE_NAMES.CERTIFICATE);
     const result = await contract.evaluateTransaction('VerifyCertificate', certificateId, ipfsHash);
-    res.json({ valid: result.toString() === 'true' });
+    // { valid, status, validFrom, validUntil, supersededBy, checkedAt }
+    res.json(JSON.parse(result.toString()));
   } catch (error) {
     res.status(500).json({ error: error.message });
   }
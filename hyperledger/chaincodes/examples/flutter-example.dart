@@ -229,7 +229,7 @@ class FabricService {
       }),
     );
     final result = json.decode(response.body);
-    return result['result'] == true;
+    return result['result']['valid'] == true;
   }
   
   // Get certificates by project
@@ -0,0 +1,183 @@
+#!/bin/bash
+
+# Load test BobCoin supply tracking
+# Four minters (the admin and User1 of Org1 and Org2) mint at the same time, each to its
+# own addresses, and the test counts how many mints were invalidated with an MVCC or
+# phantom read conflict. With supply delta records, mints by different minters never
+# conflict; before them every Mint in a block after the first did.
+#
+# Each minter sends its mints one after another. Mint allowances and supply quotas are
+# kept per minter, so two mints by the same minter in one block do conflict; that is the
+# price of bounding what a single minter key can mint.
+#
+# The test raises each minter's allowance, and sets its supply quota if the token is
+# capped, to cover its share of the run. Only run it against a test network.
+#
+# Usage: ./load-test-bobcoin.sh [mints per minter] [amount per mint]
+
+# Colors for output
+GREEN='\033[0;32m'
+BLUE='\033[0;34m'
+YELLOW='\033[1;33m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+# Configuration
+CHANNEL_NAME="mychannel"
+CHAINCODE_NAME="bobcoin"
+MINTS="${1:-10}"
+AMOUNT="${2:-1}"
+MINTERS=("1 Admin" "1 User1" "2 Admin" "2 User1")
+TOTAL=$(( MINTS * ${#MINTERS[@]} ))
+RUN_ID="$(date +%s)"
+
+# Paths
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+FABRIC_ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
+FABRIC_NETWORK_DIR="$FABRIC_ROOT/fabric-samples/test-network"
+FABRIC_BIN_DIR="$FABRIC_ROOT/fabric-samples/bin"
+RESULTS_DIR="$(mktemp -d)"
+trap 'rm -rf "$RESULTS_DIR"' EXIT
+
+# Check if network is running
+echo -e "${BLUE}Checking if Fabric network is running...${NC}"
+if ! docker ps | grep -q "peer0.org1.example.com"; then
+    echo -e "${RED}Error: Fabric network is not running. Please start it first with ./start-network.sh${NC}"
+    exit 1
+fi
+
+if ! command -v bc > /dev/null; then
+    echo -e "${RED}Error: bc is required to compare supplies${NC}"
+    exit 1
+fi
+
+# Set environment variables (Org1 admin, who sets the allowances)
+export PATH="$FABRIC_BIN_DIR:$PATH"
+export FABRIC_CFG_PATH="$FABRIC_ROOT/fabric-samples/config"
+export CORE_PEER_TLS_ENABLED=true
+
+# use_identity switches the peer CLI to a user of Org1 or Org2
+use_identity() {
+    local org=$1
+    local user=$2
+    local port=7051
+    if [ "$org" = "2" ]; then
+        port=9051
+    fi
+    export CORE_PEER_LOCALMSPID="Org${org}MSP"
+    export CORE_PEER_TLS_ROOTCERT_FILE=${FABRIC_NETWORK_DIR}/organizations/peerOrganizations/org${org}.example.com/peers/peer0.org${org}.example.com/tls/ca.crt
+    export CORE_PEER_MSPCONFIGPATH=${FABRIC_NETWORK_DIR}/organizations/peerOrganizations/org${org}.example.com/users/${user}@org${org}.example.com/msp
+    export CORE_PEER_ADDRESS=localhost:${port}
+}
+use_identity 1 Admin
+
+cd "$FABRIC_NETWORK_DIR"
+
+total_supply() {
+    peer chaincode query -C $CHANNEL_NAME -n $CHAINCODE_NAME -c '{"function":"TotalSupply","Args":[]}' | tr -d '"'
+}
+
+decimals() {
+    peer chaincode query -C $CHANNEL_NAME -n $CHAINCODE_NAME -c '{"function":"GetTokenInfo","Args":[]}' | jq -r '.decimals'
+}
+
+invoke() {
+    peer chaincode invoke \
+        -o localhost:7050 \
+        --ordererTLSHostnameOverride orderer.example.com \
+        --tls \
+        --cafile ${FABRIC_NETWORK_DIR}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem \
+        -C $CHANNEL_NAME \
+        -n $CHAINCODE_NAME \
+        --peerAddresses localhost:7051 \
+        --tlsRootCertFiles ${FABRIC_NETWORK_DIR}/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt \
+        --peerAddresses localhost:9051 \
+        --tlsRootCertFiles ${FABRIC_NETWORK_DIR}/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt \
+        --waitForEvent \
+        -c "$1"
+}
+
+# mint_all sends a minter's mints one after another, each to its own address
+mint_all() {
+    local org=$1
+    local user=$2
+    use_identity $org $user
+    for i in $(seq 1 $MINTS); do
+        invoke '{"function":"Mint","Args":["loadtest-'${RUN_ID}'-org'${org}'-'${user}'-'${i}'","'${AMOUNT}'"]}' \
+            > "$RESULTS_DIR/org${org}-${user}-${i}.log" 2>&1
+    done
+}
+
+SUPPLY_BEFORE=$(total_supply)
+DECIMALS=$(decimals)
+if [ -z "$SUPPLY_BEFORE" ] || [ -z "$DECIMALS" ]; then
+    echo -e "${RED}Failed to query $CHAINCODE_NAME; is it deployed and initialized?${NC}"
+    exit 1
+fi
+echo -e "${BLUE}Total supply before: $SUPPLY_BEFORE${NC}"
+
+# Grant each minter enough for its share of the run
+MAX_SUPPLY=$(peer chaincode query -C $CHANNEL_NAME -n $CHAINCODE_NAME -c '{"function":"GetTokenInfo","Args":[]}' | jq -r '.maxSupply // empty')
+SHARE=$(echo "$MINTS * $AMOUNT" | bc)
+echo -e "${BLUE}Granting ${#MINTERS[@]} minters $SHARE each...${NC}"
+for minter in "${MINTERS[@]}"; do
+    set -- $minter
+    MINTER_ID="Org$1MSP/$2@org$1.example.com"
+    # Mints from earlier runs in the last 24 hours still count against the allowance
+    MINTED=$(peer chaincode query -C $CHANNEL_NAME -n $CHAINCODE_NAME -c '{"function":"GetMintAllowance","Args":["'${MINTER_ID}'"]}' 2>/dev/null | jq -r '.minted // empty')
+    ALLOWANCE=$(echo "scale=$DECIMALS; ${MINTED:-0} / 10^$DECIMALS + $SHARE" | bc | sed 's/^\./0./')
+    if ! invoke '{"function":"SetMintAllowance","Args":["'${MINTER_ID}'","'${ALLOWANCE}'"]}' > "$RESULTS_DIR/setup.log" 2>&1; then
+        echo -e "${RED}Failed to set the mint allowance of $MINTER_ID:${NC}"
+        cat "$RESULTS_DIR/setup.log"
+        exit 1
+    fi
+    if [ -n "$MAX_SUPPLY" ] && ! invoke '{"function":"SetSupplyQuota","Args":["'${MINTER_ID}'","'${SHARE}'"]}' > "$RESULTS_DIR/setup.log" 2>&1; then
+        echo -e "${RED}Failed to set the supply quota of $MINTER_ID:${NC}"
+        cat "$RESULTS_DIR/setup.log"
+        exit 1
+    fi
+done
+rm -f "$RESULTS_DIR/setup.log"
+
+# Run all minters at once
+echo -e "${BLUE}Sending $MINTS mints of $AMOUNT from each of ${#MINTERS[@]} minters at once...${NC}"
+START=$(date +%s)
+for minter in "${MINTERS[@]}"; do
+    mint_all $minter &
+done
+wait
+ELAPSED=$(( $(date +%s) - START ))
+use_identity 1 Admin
+
+# Tally outcomes
+COMMITTED=$(grep -l "committed with status (VALID)" "$RESULTS_DIR"/*.log | wc -l)
+MVCC=$(grep -l "MVCC_READ_CONFLICT\|PHANTOM_READ_CONFLICT" "$RESULTS_DIR"/*.log | wc -l)
+FAILED=$(( TOTAL - COMMITTED - MVCC ))
+
+echo ""
+echo -e "Sent:            $TOTAL in ${ELAPSED}s"
+echo -e "Committed:       ${GREEN}$COMMITTED${NC}"
+echo -e "MVCC conflicts:  ${YELLOW}$MVCC${NC}"
+echo -e "Other failures:  ${RED}$FAILED${NC}"
+if [ "$FAILED" -gt 0 ]; then
+    echo -e "${YELLOW}First failure:${NC}"
+    grep -L "committed with status (VALID)\|MVCC_READ_CONFLICT\|PHANTOM_READ_CONFLICT" "$RESULTS_DIR"/*.log | head -1 | xargs cat
+fi
+
+# Every committed mint must be in the supply, and nothing else
+SUPPLY_AFTER=$(total_supply)
+EXPECTED=$(echo "$SUPPLY_BEFORE + $COMMITTED * $AMOUNT * 10^$DECIMALS" | bc)
+echo ""
+echo -e "${BLUE}Total supply after: $SUPPLY_AFTER (expected $EXPECTED)${NC}"
+
+if [ "$SUPPLY_AFTER" != "$EXPECTED" ]; then
+    echo -e "${RED}✗ Total supply does not match the committed mints${NC}"
+    exit 1
+fi
+if [ "$MVCC" -gt 0 ]; then
+    echo -e "${RED}✗ Mints by different minters conflicted${NC}"
+    exit 1
+fi
+
+echo -e "${GREEN}✓ All committed mints are in the total supply with no conflicts${NC}"
+echo -e "${YELLOW}Run CompactSupply as an admin to fold the supply deltas back into the token metadata${NC}"
@@ -48,6 +48,13 @@ if [ $? -ne 0 ]; then
     exit 1
 fi
 
+# Vendor dependencies so the shared chaincode-common module is packaged with the chaincode
+go mod vendor
+if [ $? -ne 0 ]; then
+    echo -e "${RED}Failed to vendor dependencies${NC}"
+    exit 1
+fi
+
 cd "$FABRIC_NETWORK_DIR"
 
 # Package chaincode
@@ -24,6 +24,7 @@ FABRIC_ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
 FABRIC_NETWORK_DIR="$FABRIC_ROOT/fabric-samples/test-network"
 FABRIC_BIN_DIR="$FABRIC_ROOT/fabric-samples/bin"
 CHAINCODE_PATH="$SCRIPT_DIR/certificate-registry"
+COLLECTIONS_CONFIG="$CHAINCODE_PATH/collections_config.json"
 
 # Check if network is running
 echo -e "${BLUE}Checking if Fabric network is running...${NC}"
@@ -48,6 +49,13 @@ if [ $? -ne 0 ]; then
     exit 1
 fi
 
+# Vendor dependencies so the shared chaincode-common module is packaged with the chaincode
+go mod vendor
+if [ $? -ne 0 ]; then
+    echo -e "${RED}Failed to vendor dependencies${NC}"
+    exit 1
+fi
+
 cd "$FABRIC_NETWORK_DIR"
 
 # Package chaincode
@@ -115,6 +123,7 @@ peer lifecycle chaincode approveformyorg \
     --version $CHAINCODE_VERSION \
     --package-id $PACKAGE_ID \
     --sequence $SEQUENCE \
+    --collections-config "$COLLECTIONS_CONFIG" \
     --tls \
     --cafile ${PWD}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem
 
@@ -140,6 +149,7 @@ peer lifecycle chaincode approveformyorg \
     --version $CHAINCODE_VERSION \
     --package-id $PACKAGE_ID \
     --sequence $SEQUENCE \
+    --collections-config "$COLLECTIONS_CONFIG" \
     --tls \
     --cafile ${PWD}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem
 
@@ -164,6 +174,7 @@ peer lifecycle chaincode commit \
     --name $CHAINCODE_NAME \
     --version $CHAINCODE_VERSION \
     --sequence $SEQUENCE \
+    --collections-config "$COLLECTIONS_CONFIG" \
     --tls \
     --cafile ${PWD}/organizations/ordererOrganizations/example.com/orderers/orderer.example.com/msp/tlscacerts/tlsca.example.com-cert.pem \
     --peerAddresses localhost:7051 \
@@ -208,3 +219,7 @@ echo "  4. End of project -> GetCertificatesByGroup() for rating/review"
 echo ""
 echo -e "${YELLOW}Note: RegisterProject now requires ipfsGroupHash as 10th argument${NC}"
 echo -e "${YELLOW}      See IPFS_GROUP_FLOW.md for detailed documentation${NC}"
+echo ""
+echo -e "${YELLOW}Migrate existing records as an org admin until \"done\" is true:${NC}"
+echo "  peer chaincode invoke ... -n $CHAINCODE_NAME -c '{\"function\":\"MigrateState\",\"Args\":[\"100\",\"\"]}'"
+echo "  Pass the returned bookmark as the second argument of the next call"